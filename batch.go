@@ -0,0 +1,91 @@
+package scago
+
+import (
+	"context"
+	"math/rand"
+	"runtime"
+	"sync"
+)
+
+// Result is the outcome of applying a ruleset to a single word of a
+// batch given to ApplyAll.
+type Result struct {
+	Word   string // the word as it was given
+	Result string // the changed word, or empty if Err is not nil
+	Err    error  // the error that came up while changing the word, if any
+}
+
+// ApplyAll applies the Scago's ruleset to every one of the given words
+// like Apply, spread across as many goroutines as there are CPUs to
+// use, and returns a Result for each word in the same order as the
+// words. An error for a single word is given in its Result and does
+// not stop the rest of the words being changed.
+//
+// If ctx is cancelled before every word has been changed, ApplyAll
+// stops and returns ctx.Err() along with the results, where the words
+// that weren't changed have ctx.Err() as their Err.
+//
+// Rules that apply by chance roll the dice for each word separately,
+// so with a seed given by SetSeed, the results only depend on the
+// order of the words and not on how the work was spread out.
+// The ruleset must not be changed while ApplyAll is running.
+func (s *Scago) ApplyAll(ctx context.Context, words []string) ([]Result, error) {
+	results := make([]Result, len(words))
+	for i, word := range words {
+		results[i].Word = word
+	}
+	// Each word gets its own source of random numbers, seeded in order,
+	// but only if any rule needs one
+	var seeds []int64
+	if s.chancy() {
+		seeds = make([]int64, len(words))
+		for i := range seeds {
+			if s.random != nil {
+				seeds[i] = s.random.Int63()
+			} else {
+				seeds[i] = rand.Int63()
+			}
+		}
+	}
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	for n := min(runtime.GOMAXPROCS(0), len(words)); n > 0; n-- {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				var random *rand.Rand
+				if seeds != nil {
+					random = rand.New(rand.NewSource(seeds[i]))
+				}
+				results[i].Result, results[i].Err = s.apply(words[i], &dice{random: random}, nil, nil)
+			}
+		}()
+	}
+	var err error
+	for i := range words {
+		select {
+		case indices <- i:
+			continue
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		for j := i; j < len(words); j++ {
+			results[j].Err = err
+		}
+		break
+	}
+	close(indices)
+	wg.Wait()
+	return results, err
+}
+
+// chancy returns true if any rule in s applies by chance.
+func (s *Scago) chancy() bool {
+	for r := s.rules; r != nil; r = r.next {
+		if r.chance != 0 || r.wordChance != 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package scago
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyAll(t *testing.T) {
+	s := New()
+	assert.NoError(t, s.AddCategory("P", []string{"p", "t", "k"}))
+	assert.NoError(t, s.AddCategory("B", []string{"b", "d", "g"}))
+	assert.NoError(t, s.AddRule("P > B / a_a"))
+	assert.NoError(t, s.AddRule("a > e / _#"))
+	t.Run("results in order", func(t *testing.T) {
+		assert := assert.New(t)
+		var words []string
+		for i := 0; i < 1000; i++ {
+			words = append(words, fmt.Sprintf("apa%d", i%10), "ata")
+		}
+		words = append(words, " ")
+		results, err := s.ApplyAll(context.Background(), words)
+		if !assert.NoError(err) || !assert.Len(results, len(words)) {
+			return
+		}
+		for i, word := range words[:len(words)-1] {
+			want, err := s.Apply(word)
+			assert.NoError(err)
+			assert.Equal(results[i], Result{word, want, nil})
+		}
+		// A word that fails doesn't stop the others
+		assert.Error(results[len(words)-1].Err)
+	})
+	t.Run("no words", func(t *testing.T) {
+		assert := assert.New(t)
+		results, err := s.ApplyAll(context.Background(), nil)
+		assert.NoError(err)
+		assert.Empty(results)
+	})
+	t.Run("cancelled", func(t *testing.T) {
+		assert := assert.New(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		results, err := s.ApplyAll(ctx, []string{"apa", "ata"})
+		assert.ErrorIs(err, context.Canceled)
+		if !assert.Len(results, 2) {
+			return
+		}
+		// The first word may have been sent to a worker before the
+		// context was found to be cancelled
+		assert.ErrorIs(results[1].Err, context.Canceled)
+	})
+	t.Run("seeded chance", func(t *testing.T) {
+		assert := assert.New(t)
+		words := make([]string, 200)
+		for i := range words {
+			words[i] = "aaaaaaaa"
+		}
+		run := func(procs int) []Result {
+			defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(procs))
+			s := New()
+			s.SetSeed(42)
+			assert.NoError(s.AddRule("a > e ; chance=0.5"))
+			results, err := s.ApplyAll(context.Background(), words)
+			assert.NoError(err)
+			return results
+		}
+		assert.Equal(run(1), run(4))
+	})
+}
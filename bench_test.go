@@ -0,0 +1,79 @@
+package scago
+
+import (
+	"context"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+)
+
+// loadBenchmark returns a Scago with the given ruleset from testdata
+// loaded into it.
+func loadBenchmark(b *testing.B, name string) *Scago {
+	b.Helper()
+	f, err := os.Open("testdata/" + name)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer f.Close()
+	s := New()
+	if err := s.LoadRuleset(f); err != nil {
+		b.Fatal(err)
+	}
+	return s
+}
+
+// benchmarkLexicon returns n made-up Latin-like words of the given
+// number of syllables, the same every time.
+func benchmarkLexicon(n int, syllables int) []string {
+	onsets := []string{"p", "t", "k", "b", "d", "g", "f", "s", "m", "n", "l", "r", "pl", "kl", "tr", "kw", ""}
+	nuclei := []string{"a", "e", "i", "o", "u", "ae", "au"}
+	codas := []string{"", "", "", "s", "m", "n", "l", "r", "k"}
+	random := rand.New(rand.NewSource(1))
+	words := make([]string, n)
+	for i := range words {
+		sb := &strings.Builder{}
+		for j := 0; j < syllables; j++ {
+			sb.WriteString(onsets[random.Intn(len(onsets))])
+			sb.WriteString(nuclei[random.Intn(len(nuclei))])
+			sb.WriteString(codas[random.Intn(len(codas))])
+		}
+		words[i] = sb.String()
+	}
+	return words
+}
+
+func BenchmarkApply(b *testing.B) {
+	s := loadBenchmark(b, "romance.sca")
+	for _, bm := range []struct {
+		name      string
+		syllables int
+	}{
+		{"short", 2},
+		{"medium", 4},
+		{"long", 12},
+	} {
+		words := benchmarkLexicon(1000, bm.syllables)
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := s.Apply(words[i%len(words)]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkApplyAll(b *testing.B) {
+	s := loadBenchmark(b, "romance.sca")
+	words := benchmarkLexicon(50000, 3)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := s.ApplyAll(context.Background(), words); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package scago
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Block represents a named group of consecutive rules in a ruleset,
+// e.g the changes from one stage of a language to the next. Blocks
+// can be disabled so that only part of a ruleset is applied.
+// The object forms part of a linked list via the next *Block,
+// which may be nil in case of being the last in the set.
+type Block struct {
+	name     string // the name of the block
+	disabled bool   // true if the block's rules are not to be applied
+	next     *Block // the next block in the linked list
+}
+
+// Name returns the name of b.
+func (b *Block) Name() string {
+	return b.name
+}
+
+// HasNext returns true if b is followed by another block,
+// thus false if this is the last block in the linked list.
+func (b *Block) HasNext() bool {
+	return b.next != nil
+}
+
+// Append appends a block to the end of the linked list of blocks.
+func (b *Block) Append(block *Block) {
+	if b.HasNext() {
+		b.next.Append(block)
+		return
+	}
+	b.next = block
+}
+
+// GetBlock returns the Block in s with the given name, or nil if no
+// such block exists.
+func (s *Scago) GetBlock(name string) *Block {
+	for b := s.blocks; b != nil; b = b.next {
+		if b.name == name {
+			return b
+		}
+	}
+	return nil
+}
+
+// Blocks returns the names of the blocks in s, in order.
+func (s *Scago) Blocks() []string {
+	var names []string
+	for b := s.blocks; b != nil; b = b.next {
+		names = append(names, b.name)
+	}
+	return names
+}
+
+// AddBlock starts a new block with the given name, which every rule
+// added to s from now on belongs to until the next block is started.
+// Rules added before the first block belong to no block.
+// Returns an error if the name is blank or already used.
+func (s *Scago) AddBlock(name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errors.New("block has no name")
+	}
+	if s.GetBlock(name) != nil {
+		return fmt.Errorf("block %q already exists", name)
+	}
+	b := &Block{name: name}
+	if s.blocks == nil {
+		s.blocks = b
+	} else {
+		s.blocks.Append(b)
+	}
+	s.block = b
+	return nil
+}
+
+// SetBlockEnabled sets whether the rules in the block with the given
+// name are applied. Returns an error if there is no such block.
+func (s *Scago) SetBlockEnabled(name string, enabled bool) error {
+	b := s.GetBlock(name)
+	if b == nil {
+		return fmt.Errorf("no block named %q", name)
+	}
+	b.disabled = !enabled
+	return nil
+}
+
+// SetBlockRange enables the blocks from the block named from to the
+// block named to (inclusive) and disables every other block. A blank
+// from starts at the first block and a blank to ends at the last.
+// Rules that belong to no block are unaffected. Returns an error if
+// either block does not exist or to comes before from.
+func (s *Scago) SetBlockRange(from string, to string) error {
+	names := s.Blocks()
+	first, last := 0, len(names)-1
+	for i, name := range names {
+		if name == from {
+			first = i
+		}
+		if name == to {
+			last = i
+		}
+	}
+	for _, name := range []string{from, to} {
+		if name != "" && s.GetBlock(name) == nil {
+			return fmt.Errorf("no block named %q", name)
+		}
+	}
+	if last < first {
+		return fmt.Errorf("block %q comes before block %q", to, from)
+	}
+	i := 0
+	for b := s.blocks; b != nil; b = b.next {
+		b.disabled = i < first || i > last
+		i++
+	}
+	return nil
+}
+
+// Rules returns the rules in s, in the order they are applied.
+func (s *Scago) Rules() []*Rule {
+	var rules []*Rule
+	for r := s.rules; r != nil; r = r.next {
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+// Block returns the block r belongs to, or nil if it belongs to none.
+func (r *Rule) Block() *Block {
+	return r.block
+}
+
+// Enabled returns true if r is applied, i.e neither r nor its block
+// have been disabled.
+func (r *Rule) Enabled() bool {
+	return !r.disabled && (r.block == nil || !r.block.disabled)
+}
+
+// SetEnabled sets whether r is applied. A rule that is enabled is
+// still not applied if its block is disabled.
+func (r *Rule) SetEnabled(enabled bool) {
+	r.disabled = !enabled
+}
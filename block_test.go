@@ -0,0 +1,147 @@
+package scago
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddBlock(t *testing.T) {
+	assert := assert.New(t)
+	s := New()
+	assert.NoError(s.AddRule("p > f / #_"))
+	assert.NoError(s.AddBlock("one"))
+	assert.NoError(s.AddRule("a > e"))
+	assert.NoError(s.AddBlock("two"))
+	assert.NoError(s.AddRule("e > i"))
+	assert.NoError(s.AddBlock("three"))
+	assert.NoError(s.AddBlock("four"))
+	assert.NoError(s.AddRule("k > x"))
+	assert.Equal(s.Blocks(), []string{"one", "two", "three", "four"})
+	assert.Error(s.AddBlock("two"))
+	assert.Error(s.AddBlock(" "))
+	rules := s.Rules()
+	if !assert.Len(rules, 4) {
+		return
+	}
+	assert.Nil(rules[0].Block())
+	assert.Equal(rules[1].Block().Name(), "one")
+	assert.Equal(rules[2].Block().Name(), "two")
+	assert.Equal(rules[3].Block().Name(), "four")
+}
+
+func TestSetBlockRange(t *testing.T) {
+	tests := []struct {
+		from string
+		to   string
+		want string
+	}{
+		{"", "", "fixi"},
+		{"one", "one", "feke"},
+		{"two", "", "faxa"},
+		{"", "two", "fiki"},
+		{"three", "four", "faxa"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from+"-"+tt.to, func(t *testing.T) {
+			assert := assert.New(t)
+			s := New()
+			assert.NoError(s.AddRule("p > f / #_"))
+			assert.NoError(s.AddBlock("one"))
+			assert.NoError(s.AddRule("a > e"))
+			assert.NoError(s.AddBlock("two"))
+			assert.NoError(s.AddRule("e > i"))
+			assert.NoError(s.AddBlock("three"))
+			assert.NoError(s.AddBlock("four"))
+			assert.NoError(s.AddRule("k > x"))
+			if !assert.NoError(s.SetBlockRange(tt.from, tt.to)) {
+				return
+			}
+			got, err := s.Apply("paka")
+			assert.NoError(err)
+			assert.Equal(got, tt.want)
+		})
+	}
+	t.Run("invalid ranges", func(t *testing.T) {
+		assert := assert.New(t)
+		s := New()
+		assert.NoError(s.AddBlock("one"))
+		assert.NoError(s.AddBlock("two"))
+		assert.Error(s.SetBlockRange("five", ""))
+		assert.Error(s.SetBlockRange("", "five"))
+		assert.Error(s.SetBlockRange("two", "one"))
+	})
+}
+
+func TestEnabled(t *testing.T) {
+	assert := assert.New(t)
+	s := New()
+	assert.NoError(s.AddRule("p > f / #_"))
+	assert.NoError(s.AddBlock("one"))
+	assert.NoError(s.AddRule("a > e"))
+	assert.NoError(s.AddBlock("two"))
+	assert.NoError(s.AddRule("k > x"))
+	assert.NoError(s.SetBlockEnabled("two", false))
+	assert.Error(s.SetBlockEnabled("five", false))
+	rules := s.Rules()
+	rules[0].SetEnabled(false)
+	assert.False(rules[0].Enabled())
+	assert.True(rules[1].Enabled())
+	assert.False(rules[2].Enabled())
+	got, err := s.Apply("paka")
+	assert.NoError(err)
+	assert.Equal(got, "peke")
+	rules[2].SetEnabled(false)
+	assert.NoError(s.SetBlockEnabled("two", true))
+	assert.False(rules[2].Enabled())
+}
+
+func TestExportBlocks(t *testing.T) {
+	assert := assert.New(t)
+	s := New()
+	assert.NoError(s.AddRule("p > f / #_"))
+	assert.NoError(s.AddBlock("one"))
+	assert.NoError(s.AddRule("a > e"))
+	assert.NoError(s.AddBlock("two"))
+	assert.NoError(s.AddRule("e > i"))
+	assert.NoError(s.AddBlock("three"))
+	assert.NoError(s.AddBlock("four"))
+	assert.NoError(s.AddRule("k > x"))
+	s.Rules()[2].SetEnabled(false)
+	assert.NoError(s.SetBlockEnabled("four", false))
+	sb := &strings.Builder{}
+	if !assert.NoError(s.Export(sb)) {
+		return
+	}
+	assert.Equal(sb.String(), `p > f / #_
+block: one
+a > e
+block: two
+e > i ; off
+block: three
+block: four
+k > x ; off
+`)
+}
+
+func TestExportDisabledBlock(t *testing.T) {
+	assert := assert.New(t)
+	s := New()
+	assert.NoError(s.AddBlock("One"))
+	assert.NoError(s.AddRule("a > e"))
+	assert.NoError(s.AddBlock("Two"))
+	assert.NoError(s.AddRule("p > b"))
+	assert.NoError(s.SetBlockEnabled("Two", false))
+	sb := &strings.Builder{}
+	if !assert.NoError(s.Export(sb)) {
+		return
+	}
+	reloaded := New()
+	if !assert.NoError(reloaded.LoadRuleset(strings.NewReader(sb.String()))) {
+		return
+	}
+	got, err := reloaded.Apply("pa")
+	assert.NoError(err)
+	assert.Equal(got, "pe")
+}
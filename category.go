@@ -12,6 +12,7 @@ import (
 type Category struct {
 	identifier string    // the identifying name of the category
 	pattern    string    // the sounds in the category as a regexp string
+	sounds     []string  // the sounds in the category, in order
 	next       *Category // the next category in the linked list
 }
 
@@ -31,6 +32,35 @@ func (c *Category) Append(category *Category) {
 	c.next = category
 }
 
+// Identifier returns the identifying name of c.
+func (c *Category) Identifier() string {
+	return c.identifier
+}
+
+// String returns the definition of c as it would be written in a
+// ruleset, e.g "P = p, t, k".
+func (c *Category) String() string {
+	return c.identifier + " = " + strings.Join(c.sounds, ", ")
+}
+
+// Sounds returns the sounds in c, in order.
+func (c *Category) Sounds() []string {
+	sounds := make([]string, len(c.sounds))
+	copy(sounds, c.sounds)
+	return sounds
+}
+
+// Index returns the position of the given sound in c, or -1 if the
+// sound is not in c.
+func (c *Category) Index(sound string) int {
+	for i, s := range c.sounds {
+		if s == sound {
+			return i
+		}
+	}
+	return -1
+}
+
 // GetCategory returns the Category in s that corresponds to the
 // given identifier string, or nil if no such category exists.
 func (s *Scago) GetCategory(identifier string) *Category {
@@ -42,6 +72,26 @@ func (s *Scago) GetCategory(identifier string) *Category {
 	return nil
 }
 
+// negatedCategory returns a regexp pattern matching any single segment
+// that is not in a category, if the given element is a negated
+// category written as !V or [^V]. Otherwise it returns false.
+func (s *Scago) negatedCategory(element string) (string, bool) {
+	var identifier string
+	switch {
+	case strings.HasPrefix(element, "!"):
+		identifier = element[1:]
+	case strings.HasPrefix(element, "[^") && strings.HasSuffix(element, "]"):
+		identifier = element[2 : len(element)-1]
+	default:
+		return "", false
+	}
+	c := s.GetCategory(identifier)
+	if c == nil {
+		return "", false
+	}
+	return s.segmenter.ExcludingPattern(c.sounds), true
+}
+
 // AddCategory creates a new category with the given identifier and sounds and
 // adds it to s.
 // Returns an error if an error was encountered.
@@ -78,5 +128,5 @@ func NewCategory(identifier string, sounds []string) (*Category, error) {
 		return nil, err
 	}
 	// Return category with the given regexp pattern
-	return &Category{identifier, exp, nil}, nil
+	return &Category{identifier, exp, sounds, nil}, nil
 }
@@ -14,4 +14,16 @@ func TestNewCategory(t *testing.T) {
 	}
 	assert.Equal(got.identifier, "K")
 	assert.Equal(got.pattern, "(a|b|c)")
+	assert.Equal(got.sounds, []string{"a", "b", "c"})
+}
+
+func TestCategoryIndex(t *testing.T) {
+	assert := assert.New(t)
+	c, err := NewCategory("K", []string{"a", "b", "c"})
+	if err != nil {
+		t.Fatalf("NewCategory returned error: %s", err)
+	}
+	assert.Equal(c.Index("a"), 0)
+	assert.Equal(c.Index("c"), 2)
+	assert.Equal(c.Index("d"), -1)
 }
@@ -2,6 +2,7 @@ package scago
 
 import (
 	"errors"
+	"fmt"
 	"strconv"
 	"strings"
 )
@@ -9,11 +10,82 @@ import (
 // Change represents the set of sounds or categories
 // that are to be changed to within a sound change rule.
 // This often takes the form of a replacement but may also
-// represent a movement.
+// represent a movement, or a mapping of each sound in the
+// target's category to the sound at the same position in another.
 type Change struct {
 	replacement string
 	movement    int
 	deletion    bool
+	category    *Category         // if mapping categories, the category mapped to
+	source      *Category         // if mapping categories, the target's category
+	stress      Stress            // if not Unstressed, the stress to give the target's syllable instead
+	features    FeatureMatrix     // if changing features, the feature values to set
+	mapping     map[string]string // if changing features, the sound each declared sound becomes
+}
+
+// String returns the change in canonical scago notation.
+func (c *Change) String() string {
+	if c == nil || c.deletion {
+		return ""
+	}
+	if c.stress != Unstressed {
+		return c.stress.Mark()
+	}
+	s := c.replacement
+	if c.category != nil {
+		s = c.category.identifier
+	}
+	if c.features != nil {
+		s = c.features.String()
+	}
+	if c.movement != 0 {
+		if s != "" {
+			s += " "
+		}
+		s += "@" + strconv.Itoa(c.movement)
+	}
+	return s
+}
+
+// Replace returns what the given matched target is to be replaced
+// with by c. If c has no replacement (e.g a plain movement), the
+// target is returned unchanged. If c changes features, the target
+// becomes the declared sound with its features changed, or is left
+// unchanged if there is no such sound.
+func (c *Change) Replace(target string) string {
+	// A target whose features can't be changed is left as it is
+	if c.features != nil {
+		if to, ok := c.mapping[target]; ok {
+			return to
+		}
+		return target
+	}
+	if c.category != nil && c.source != nil {
+		if i := c.source.Index(target); i >= 0 {
+			return c.category.sounds[i]
+		}
+	}
+	if c.replacement != "" {
+		return c.replacement
+	}
+	return target
+}
+
+// linkTarget prepares c to be carried out on the given target,
+// checking that a change to a category has a category of the same
+// size to map from. Returns an error if not.
+func (c *Change) linkTarget(target *Target) error {
+	if c == nil || c.category == nil {
+		return nil
+	}
+	if target == nil || target.category == nil {
+		return fmt.Errorf("change to category %s needs a single category as its target", c.category.identifier)
+	}
+	if len(target.category.sounds) != len(c.category.sounds) {
+		return fmt.Errorf("categories %s and %s are not the same size", target.category.identifier, c.category.identifier)
+	}
+	c.source = target.category
+	return nil
 }
 
 // ParseChange returns a Change object based on a given input
@@ -24,21 +96,44 @@ type Change struct {
 func (s *Scago) ParseChange(input string) (*Change, error) {
 	input = strings.TrimSpace(input)
 	if input == "" {
-		return &Change{"", 0, true}, nil
+		return &Change{deletion: true}, nil
+	}
+	// A stress mark on its own stresses the target's syllable
+	if stress, size := stressAt(input); size == len(input) {
+		return &Change{stress: stress}, nil
 	}
 	change := &Change{}
 	split := strings.Split(input, "@")
 	if len(split) == 1 {
 		change.replacement = input
-	} else if len(split) >= 2 {
+	} else if len(split) == 2 {
 		change.replacement = strings.TrimSpace(split[0])
 		i, err := strconv.Atoi(strings.TrimSpace(split[1]))
 		if err != nil {
-			return nil, err
+			return nil, errorAt(countNonSpace(split[0])+1, fmt.Errorf("invalid movement %q after @ in change", strings.TrimSpace(split[1])))
 		}
 		change.movement = i
 	} else {
-		return nil, errors.New("too many '@' operators in change")
+		return nil, errorAt(countNonSpace(split[0])+1+countNonSpace(split[1]), errors.New("too many '@' operators in change"))
+	}
+	// A feature matrix sets those features of the target
+	if isFeatureMatrix(change.replacement) {
+		m, err := ParseFeatureMatrix(change.replacement)
+		if err != nil {
+			return nil, err
+		}
+		change.mapping, err = s.featureMapping(m)
+		if err != nil {
+			return nil, err
+		}
+		change.features = m
+		change.replacement = ""
+		return change, nil
+	}
+	// A replacement that is a category maps from the target's category
+	if c := s.GetCategory(change.replacement); c != nil {
+		change.category = c
+		change.replacement = ""
 	}
 	return change, nil
 }
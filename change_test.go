@@ -68,4 +68,28 @@ func TestParseChange(t *testing.T) {
 		assert.Empty(got.movement)
 		assert.True(got.deletion)
 	})
+	t.Run("parse change: category", func(t *testing.T) {
+		assert := assert.New(t)
+		s := New()
+		if err := s.AddCategory("B", []string{"b", "d", "g"}); err != nil {
+			t.Fatalf("error when adding category")
+		}
+		got, err := s.ParseChange(" B ")
+		if err != nil {
+			t.Fatalf("%s returned err: %s", t.Name(), err)
+		}
+		assert.Empty(got.replacement)
+		assert.Equal(got.category, s.GetCategory("B"))
+		assert.False(got.deletion)
+	})
+}
+
+func TestReplace(t *testing.T) {
+	assert := assert.New(t)
+	p, _ := NewCategory("P", []string{"p", "t", "k"})
+	b, _ := NewCategory("B", []string{"b", "d", "g"})
+	assert.Equal((&Change{replacement: "x"}).Replace("p"), "x")
+	assert.Equal((&Change{movement: 2}).Replace("p"), "p")
+	assert.Equal((&Change{category: b, source: p}).Replace("t"), "d")
+	assert.Equal((&Change{category: b, source: p}).Replace("k"), "g")
 }
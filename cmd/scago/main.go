@@ -1,44 +1,390 @@
 package main
 
 import (
+	"bufio"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
 	"go.m5ka.dev/scago"
+	"go.m5ka.dev/scago/convert"
+	"go.m5ka.dev/scago/convert/lexurgy"
+	"go.m5ka.dev/scago/convert/sca2"
+	"go.m5ka.dev/scago/convert/sce"
 )
 
 func main() {
-	//inputFile := flag.String("i", "", "file containing a list of input words to be changed")
-	//outputFile := flag.String("o", "", "filename for the output of the sound changes")
-	//rulesetFile := flag.String("f", "", "file containing a list of rules to be applied to all words")
+	if len(os.Args) > 1 && os.Args[1] == "test" {
+		os.Exit(runTests(os.Args[2:], os.Stdout))
+	}
+
+	inputFile := flag.String("i", "", "file containing a list of input words to be changed (- for stdin)")
+	outputFile := flag.String("o", "", "filename for the output of the sound changes (default stdout)")
+	rulesetFile := flag.String("f", "", "file containing a list of rules to be applied to all words")
 	ruleLiteral := flag.String("r", "", "a single rule to apply to the word(s)")
+	verbose := flag.Bool("v", false, "print the derivation of each word to stderr")
+	export := flag.Bool("export", false, "write the ruleset in scago notation to the output instead of changing any words")
+	format := flag.String("format", "scago", "format of the ruleset file: scago, sca2, lexurgy or sce")
+	fromBlock := flag.String("from", "", "name of the first block of rules to apply")
+	toBlock := flag.String("to", "", "name of the last block of rules to apply")
+	skipBlocks := flag.String("skip", "", "comma-separated list of blocks not to apply")
+	skipRules := flag.String("skip-rules", "", "comma-separated list of rule numbers (from 1) not to apply")
+	stages := flag.Bool("stages", false, "print a table of each word's form at every stage of the ruleset")
+	unapply := flag.Bool("unapply", false, "list the forms each word could have come from instead of changing it")
+	seed := flag.Int64("seed", 0, "seed for rules that apply by chance, so that runs can be repeated (default random)")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), `usage: scago [flags] [word]
+       scago test [-v] [-seed n] ruleset.sca ...
+
+Only "test" as the very first argument starts the test subcommand, so
+after any flags it is changed like any other word: scago -r "t > d" test`)
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 	inputLiteral := flag.Arg(0)
-	if inputLiteral == "" {
-		fmt.Println("No word(s) specified.")
-		return
-	}
 
 	s := scago.New()
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "seed" {
+			s.SetSeed(*seed)
+		}
+	})
 
-	// TODO: allow reading of ruleset file for adding rules to ScagoInstance
+	if *rulesetFile != "" {
+		f, err := os.Open(*rulesetFile)
+		if err != nil {
+			fmt.Println("Error opening ruleset:", err)
+			return
+		}
+		err = loadRuleset(s, f, *format)
+		f.Close()
+		if err != nil {
+			fmt.Println("Error loading ruleset:", err)
+			printParseError(err)
+			return
+		}
+	}
 
 	if *ruleLiteral != "" {
 		err := s.AddRule(*ruleLiteral)
 		if err != nil {
 			fmt.Println("Error adding rule:", err)
+			printParseError(err)
 			return
 		}
-	} else {
+	} else if *rulesetFile == "" {
 		fmt.Println("No rule(s) specified.")
 		return
 	}
 
-	// TODO: allow reading of input file for processing words to Apply
+	if err := selectRules(s, *fromBlock, *toBlock, *skipBlocks, *skipRules); err != nil {
+		fmt.Println("Error selecting rules:", err)
+		return
+	}
+
+	// Words come from the input file if one is given, then from the
+	// command line, and otherwise from stdin
+	var input io.Reader
+	switch {
+	case *inputFile != "" && *inputFile != "-":
+		f, err := os.Open(*inputFile)
+		if err != nil {
+			fmt.Println("Error opening input:", err)
+			return
+		}
+		defer f.Close()
+		input = f
+	case *inputFile == "" && inputLiteral != "":
+		input = strings.NewReader(inputLiteral)
+	default:
+		input = os.Stdin
+	}
+
+	var output io.Writer = os.Stdout
+	if *outputFile != "" {
+		f, err := os.Create(*outputFile)
+		if err != nil {
+			fmt.Println("Error creating output:", err)
+			return
+		}
+		defer f.Close()
+		output = f
+	}
 
-	output, err := s.Apply(inputLiteral)
-	if err == nil {
-		fmt.Println(output)
-	} else {
+	if *export {
+		if err := s.Export(output); err != nil {
+			fmt.Println("Error exporting ruleset:", err)
+		}
+		return
+	}
+
+	var failed int
+	var err error
+	switch {
+	case *unapply:
+		failed, err = unapplyLexicon(s, input, output, os.Stderr)
+	case *stages:
+		failed, err = applyStages(s, input, output, os.Stderr)
+	default:
+		failed, err = applyLexicon(s, input, output, os.Stderr, *verbose)
+	}
+	if err != nil {
 		fmt.Println("Something went wrong:", err)
+		return
+	}
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "%d word(s) could not be changed.\n", failed)
+	}
+}
+
+// applyLexicon applies s to every word in in, one word per line, and
+// writes the results to out in the same order, as described by
+// eachWord. Returns the number of words that failed, and any error
+// encountered while reading or writing. If verbose is true, the
+// derivation of each word is also written to errOut.
+func applyLexicon(s *scago.Scago, in io.Reader, out io.Writer, errOut io.Writer, verbose bool) (int, error) {
+	return eachWord(in, out, errOut, func(word string) (string, error) {
+		result, trace, err := s.ApplyTrace(word)
+		if verbose {
+			printDerivation(errOut, "", word, trace)
+		}
+		return result, err
+	})
+}
+
+// unapplyLexicon finds the forms that s could have changed each word
+// in in into, one word per line, and writes them to out as a
+// comma-separated list, one line per word, as described by eachWord.
+// The forms found before a search was stopped are still written.
+func unapplyLexicon(s *scago.Scago, in io.Reader, out io.Writer, errOut io.Writer) (int, error) {
+	return eachWord(in, out, errOut, func(word string) (string, error) {
+		ancestors, err := s.Unapply(word)
+		return strings.Join(ancestors, ", "), err
+	})
+}
+
+// applyStages applies s to every word in in, one word per line, and
+// writes a table of the form of each word at every stage of s to out,
+// one word per row and one stage per column, headed by the names of
+// the stages. Lines are handled as described by eachWord, and the
+// stages after a failing rule are left blank.
+func applyStages(s *scago.Scago, in io.Reader, out io.Writer, errOut io.Writer) (int, error) {
+	names := s.Stages()
+	if len(names) == 0 {
+		return 0, errors.New("the ruleset has no stages")
+	}
+	if _, err := fmt.Fprintln(out, strings.Join(names, " | ")); err != nil {
+		return 0, err
+	}
+	return eachWord(in, out, errOut, func(word string) (string, error) {
+		forms, err := s.ApplyStages(word)
+		for len(forms) < len(names) {
+			forms = append(forms, "")
+		}
+		return strings.Join(forms, " | "), err
+	})
+}
+
+// eachWord calls change on every word in in, one word per line, and
+// writes the line it returns for each to out in the same order. Blank
+// lines are kept as they are. A word that fails is reported to errOut
+// with its line number, and whatever change returned for it (usually a
+// blank line) is still written so that the output stays aligned with
+// the input. Returns the number of words that failed, and any error
+// encountered while reading or writing.
+func eachWord(in io.Reader, out io.Writer, errOut io.Writer, change func(word string) (string, error)) (int, error) {
+	scanner := bufio.NewScanner(in)
+	writer := bufio.NewWriter(out)
+	failed := 0
+	for n := 1; scanner.Scan(); n++ {
+		word := strings.TrimSpace(scanner.Text())
+		if word == "" {
+			writer.WriteString("\n")
+			continue
+		}
+		line, err := change(word)
+		if err != nil {
+			fmt.Fprintf(errOut, "line %d (%s): %s\n", n, word, err)
+			failed++
+		}
+		writer.WriteString(line)
+		writer.WriteString("\n")
+	}
+	if err := scanner.Err(); err != nil {
+		writer.Flush()
+		return failed, err
+	}
+	return failed, writer.Flush()
+}
+
+// selectRules disables the blocks outside the range from..to, the
+// blocks named in skipBlocks and the rules numbered in skipRules, both
+// of which are comma-separated lists.
+func selectRules(s *scago.Scago, from string, to string, skipBlocks string, skipRules string) error {
+	if from != "" || to != "" {
+		if err := s.SetBlockRange(from, to); err != nil {
+			return err
+		}
+	}
+	for _, name := range splitFlag(skipBlocks) {
+		if err := s.SetBlockEnabled(name, false); err != nil {
+			return err
+		}
+	}
+	rules := s.Rules()
+	for _, number := range splitFlag(skipRules) {
+		n, err := strconv.Atoi(number)
+		if err != nil || n < 1 || n > len(rules) {
+			return fmt.Errorf("no rule number %s", number)
+		}
+		rules[n-1].SetEnabled(false)
+	}
+	return nil
+}
+
+// splitFlag splits a comma-separated flag value, leaving out any
+// blank items.
+func splitFlag(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// runTests runs the tests written in each of the rulesets given in
+// args, as for "scago test ruleset.sca ...", and reports any that fail
+// to out, along with the rule at which each derivation went wrong.
+// Returns the exit code: 0 if every test passed, 1 if any failed, or 2
+// if a ruleset couldn't be loaded.
+func runTests(args []string, out io.Writer) int {
+	flags := flag.NewFlagSet("test", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: scago test [-v] [-seed n] ruleset.sca ...")
+		flags.PrintDefaults()
+	}
+	verbose := flags.Bool("v", false, "print the derivation of each word whose test fails")
+	seed := flags.Int64("seed", 0, "seed for rules that apply by chance (default random)")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+	total, failed := 0, 0
+	for _, file := range flags.Args() {
+		s := scago.New()
+		flags.Visit(func(f *flag.Flag) {
+			if f.Name == "seed" {
+				s.SetSeed(*seed)
+			}
+		})
+		f, err := os.Open(file)
+		if err != nil {
+			fmt.Fprintln(out, "Error opening ruleset:", err)
+			return 2
+		}
+		err = s.LoadRuleset(f)
+		f.Close()
+		if err != nil {
+			fmt.Fprintf(out, "Error loading ruleset %s: %s\n", file, err)
+			return 2
+		}
+		rules := s.Rules()
+		number := func(r *scago.Rule) int {
+			return slices.Index(rules, r) + 1
+		}
+		for _, failure := range s.RunTests() {
+			t := failure.Test
+			fmt.Fprintf(out, "%s:%d: %s", file, t.Line(), t)
+			if t.Rule() != nil {
+				fmt.Fprintf(out, " (rule %d: %s)", number(t.Rule()), t.Rule())
+			}
+			if failure.Err != nil {
+				fmt.Fprintf(out, ": error: %s\n", failure.Err)
+			} else {
+				fmt.Fprintf(out, ": got %s\n", failure.Got)
+			}
+			if t.Rule() == nil {
+				if failure.Rule != nil {
+					fmt.Fprintf(out, "  went wrong at rule %d: %s\n", number(failure.Rule), failure.Rule)
+				} else {
+					fmt.Fprintln(out, "  no single rule could be blamed")
+				}
+			}
+			if *verbose && t.Rule() == nil {
+				printDerivation(out, "  ", t.Input(), failure.Trace)
+			}
+			failed++
+		}
+		total += len(s.Tests())
+	}
+	if failed > 0 {
+		fmt.Fprintf(out, "%d of %d test(s) failed.\n", failed, total)
+		return 1
+	}
+	fmt.Fprintf(out, "All %d test(s) passed.\n", total)
+	return 0
+}
+
+// loadRuleset loads the ruleset in r into s, translating it from the
+// given format if it isn't scago's own. Anything that couldn't be
+// translated is reported on stderr.
+func loadRuleset(s *scago.Scago, r io.Reader, format string) error {
+	var load func(*scago.Scago, io.Reader) ([]convert.Problem, error)
+	switch format {
+	case "scago":
+		return s.LoadRuleset(r)
+	case "sca2":
+		load = sca2.Load
+	case "lexurgy":
+		load = lexurgy.Load
+	case "sce":
+		load = sce.Load
+	default:
+		return fmt.Errorf("unknown ruleset format %q", format)
+	}
+	problems, err := load(s, r)
+	for _, problem := range problems {
+		fmt.Fprintln(os.Stderr, problem)
+	}
+	return err
+}
+
+// printParseError prints the rule that err is about with a marker
+// pointing to the column of the error, if err is a *scago.ParseError.
+func printParseError(err error) {
+	var pe *scago.ParseError
+	if !errors.As(err, &pe) {
+		return
+	}
+	fmt.Println("  " + pe.Rule)
+	fmt.Println("  " + strings.Repeat(" ", pe.Offset) + "^")
+}
+
+// printDerivation writes the derivation of word to w, one rule that
+// changed the word per line, along with whether each roll of the dice
+// fired for rules that apply by chance. Each line starts with indent.
+func printDerivation(w io.Writer, indent string, word string, trace []scago.Step) {
+	fmt.Fprintln(w, indent+word)
+	for _, step := range trace {
+		fmt.Fprintf(w, "%s  %s  →  %s", indent, step.Rule, step.Result)
+		if len(step.Rolls) > 0 {
+			rolls := make([]string, len(step.Rolls))
+			for i, fired := range step.Rolls {
+				rolls[i] = "no"
+				if fired {
+					rolls[i] = "yes"
+				}
+			}
+			fmt.Fprintf(w, "  [dice: %s]", strings.Join(rolls, ", "))
+		}
+		fmt.Fprintln(w)
 	}
 }
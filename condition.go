@@ -2,8 +2,11 @@ package scago
 
 import (
 	"errors"
+	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 // Condition represents a word's environment that can be
@@ -13,11 +16,26 @@ import (
 // with certain adjacent sounds or other word-environmental
 // factors.
 type Condition struct {
-	global  bool           // true if condition is global (whole word pattern)
-	pre     *regexp.Regexp // if local, pattern to check for before index
-	post    *regexp.Regexp // if local, pattern to check for after index
-	pattern *regexp.Regexp // if global, pattern to check for
-	next    *Condition     // next condition in the linked list
+	global   bool             // true if condition is global (whole word pattern)
+	pre      *regexp.Regexp   // if local, pattern to check for before index
+	post     *regexp.Regexp   // if local, pattern to check for after index
+	pattern  *regexp.Regexp   // if global, pattern to check for
+	syllabic bool             // true if the patterns are matched with syllable boundaries marked
+	position SyllablePosition // if not 0, the position in its syllable the target must be in
+	syllable int              // if not 0, the syllable the target must be in (1 is first, -1 is last)
+	stresses []Stress         // if not empty, the stresses the target's syllable may have
+	source   string           // the condition as written, without spaces
+	next     *Condition       // next condition in the linked list
+}
+
+// String returns the condition, and any conditions following it in
+// the linked list, in canonical scago notation.
+func (c *Condition) String() string {
+	var conditions []string
+	for ; c != nil; c = c.next {
+		conditions = append(conditions, c.source)
+	}
+	return strings.Join(conditions, ", ")
 }
 
 func (c *Condition) HasNext() bool {
@@ -32,37 +50,168 @@ func (c *Condition) Append(condition *Condition) {
 	}
 }
 
+// ExpandPatternToRegex compiles a pattern as written in a condition
+// into a regexp, expanding any category identifiers into the sounds
+// they stand for, and any category negated as [^V] into a pattern for
+// a segment not in it. Optional elements (X), wildcards (… or *),
+// repeated elements (X+) and syllable boundaries ($) are expanded as
+// described by expandPattern. If initial or final is true, the regexp
+// is anchored to the start or end of the text respectively. Returns
+// nil if the pattern is empty.
 func (s *Scago) ExpandPatternToRegex(pattern string, initial bool, final bool) (*regexp.Regexp, error) {
-	pattern = strings.TrimSpace(pattern)
-	chars := strings.Split(pattern, "")
+	pattern = strings.Join(strings.Fields(pattern), "")
+	syllabic := isSyllabic(pattern)
+	if syllabic && (s.segmenter == nil || s.segmenter.template == nil) {
+		return nil, errorAt(strings.Index(pattern, "$"), errors.New("syllable boundary used without a syllable template"))
+	}
+	expanded, err := s.expandPattern(pattern, syllabic)
+	if err != nil {
+		return nil, err
+	}
+	if expanded == "" {
+		return nil, nil
+	}
 	sb := &strings.Builder{}
 	if initial {
 		sb.WriteString("^")
-	}
-	for _, c := range chars {
-		c = strings.TrimSpace(c)
-		if c == "" {
-			continue
-		}
-		if cat := s.GetCategory(c); cat != nil {
-			sb.WriteString(cat.pattern)
-		} else {
-			sb.WriteString(c)
+		if syllabic {
+			sb.WriteString(syllableSeparator)
 		}
 	}
+	sb.WriteString(expanded)
 	if final {
+		if syllabic {
+			sb.WriteString(syllableSeparator)
+		}
 		sb.WriteString("$")
 	}
-	if sb.Len() == 0 || sb.String() == "^" || sb.String() == "$" {
-		return nil, nil
-	}
 	if re, err := regexp.Compile(sb.String()); err != nil {
 		return nil, err
 	} else {
+		re.Longest()
 		return re, nil
 	}
 }
 
+// expandPattern expands a pattern as written in a condition, without
+// whitespace, into a regexp string. Each element of the pattern is one
+// of the following:
+//
+//	V      a category, matching any of its sounds
+//	[^V]   a negated category, matching any segment not in V
+//	[+f]   a feature matrix, matching any declared sound with its features
+//	(X)    an optional element, matching X or nothing
+//	… or * a wildcard, matching any number of segments (even none)
+//	X+     a repeated element, matching X one or more times
+//	$      a syllable boundary, or the start or end of the word
+//	a      a segment, matched as written, even if it is a character
+//	       such as . or | that has a meaning in a regexp
+//
+// Anything else in brackets, such as a character class, is passed
+// through to the regexp as written. If syllabic is true, the pattern
+// is to be matched against a word with its syllable boundaries marked
+// by $, so any of these may come between two elements.
+//
+// An error is found at the element that could not be expanded.
+func (s *Scago) expandPattern(pattern string, syllabic bool) (string, error) {
+	separator := ""
+	if syllabic {
+		separator = syllableSeparator
+	}
+	var elements []string
+	for length := len(pattern); pattern != ""; {
+		// The offset of the element in the pattern
+		offset := length - len(pattern)
+		var element string
+		var n int
+		switch {
+		case strings.HasPrefix(pattern, "["):
+			n = strings.Index(pattern, "]") + 1
+			if n == 0 {
+				return "", errorAt(offset, errors.New("missing ] in condition"))
+			}
+			element = pattern[:n]
+			if negated, ok := s.negatedCategory(element); ok {
+				element = negated
+			} else if isFeatureMatrix(element) {
+				c, err := s.featureCategory(element)
+				if err != nil {
+					return "", errorAt(offset, err)
+				}
+				element = c.pattern
+			}
+		case strings.HasPrefix(pattern, "("):
+			n = closingParenthesis(pattern) + 1
+			if n == 0 {
+				return "", errorAt(offset, errors.New("missing ) in condition"))
+			}
+			optional, err := s.expandPattern(pattern[1:n-1], syllabic)
+			if err != nil {
+				return "", errorAt(offset+1, err)
+			}
+			if optional == "" {
+				return "", errorAt(offset, errors.New("empty optional element in condition"))
+			}
+			element = "(?:" + optional + ")?"
+		case strings.HasPrefix(pattern, ")"):
+			return "", errorAt(offset, errors.New("unexpected ) in condition"))
+		case strings.HasPrefix(pattern, "…") || strings.HasPrefix(pattern, "*"):
+			_, n = utf8.DecodeRuneInString(pattern)
+			element = "(?:" + s.segmenter.ExcludingPattern(nil) + separator + ")*"
+		case strings.HasPrefix(pattern, "$"):
+			n, element = 1, `(?:\$|#)`
+		case strings.HasPrefix(pattern, "+"):
+			if len(elements) == 0 {
+				return "", errorAt(offset, errors.New("+ must follow an element in condition"))
+			}
+			elements[len(elements)-1] = "(?:" + elements[len(elements)-1] + separator + ")+"
+			pattern = pattern[1:]
+			continue
+		default:
+			// Category identifiers are single characters
+			_, size := utf8.DecodeRuneInString(pattern)
+			if cat := s.GetCategory(pattern[:size]); cat != nil {
+				n, element = size, cat.pattern
+			} else {
+				n = s.segmenter.nextSegment(pattern)
+				element = regexp.QuoteMeta(pattern[:n])
+			}
+		}
+		elements = append(elements, element)
+		pattern = pattern[n:]
+	}
+	return strings.Join(elements, separator), nil
+}
+
+// syllableSeparator matches the syllable boundary that may come
+// between two elements of a pattern matched against a word with its
+// syllable boundaries marked.
+const syllableSeparator = `\$?`
+
+// isSyllabic returns true if the given condition pattern refers to
+// syllable boundaries.
+func isSyllabic(pattern string) bool {
+	return strings.Contains(pattern, "$")
+}
+
+// closingParenthesis returns the index of the parenthesis closing the
+// one at the start of pattern, or -1 if it isn't closed.
+func closingParenthesis(pattern string) int {
+	depth := 0
+	for i, r := range pattern {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
 // ParseCondition returns a Condition based on the given input
 // string, corresponding to the condition string as would be
 // written in the scago sound change notation.
@@ -76,38 +225,49 @@ func (s *Scago) ParseCondition(input string) (*Condition, error) {
 	}
 	// Split conditions by comma for multiple and loop through,
 	// making a chain of conditions
-	split := strings.Split(input, ",")
+	split := splitElements(input)
 	var conditions *Condition
+	// The offset of each condition in the input, not counting
+	// whitespace, so that errors can be found within it
+	offset := 0
 	for _, cond := range split {
-		c := &Condition{}
-		cond = strings.TrimSpace(cond)
+		cond = strings.Join(strings.Fields(cond), "")
+		at := offset
+		offset += len(cond) + 1
 		// Ignore blank conditions
 		if cond == "" {
 			continue
 		}
+		c := &Condition{source: cond, syllabic: isSyllabic(cond)}
 		// Determine global or local condition
 		condSplit := strings.Split(cond, "_")
-		if len(condSplit) == 1 {
+		if strings.HasPrefix(cond, "{") && strings.HasSuffix(cond, "}") {
+			if err := s.parseSyllableCondition(c, cond[1:len(cond)-1]); err != nil {
+				return nil, errorAt(at, err)
+			}
+		} else if len(condSplit) == 1 {
 			c.global = true
 			pattern, err := s.ExpandPatternToRegex(cond, false, false)
 			if err != nil {
-				return nil, err
+				return nil, errorAt(at, err)
 			}
 			c.pattern = pattern
 		} else if len(condSplit) == 2 {
 			c.global = false
 			re, err := s.ExpandPatternToRegex(condSplit[0], false, true)
 			if err != nil {
-				return nil, err
+				return nil, errorAt(at, err)
 			}
 			c.pre = re
 			re, err = s.ExpandPatternToRegex(condSplit[1], true, false)
 			if err != nil {
-				return nil, err
+				return nil, errorAt(at+len(condSplit[0])+1, err)
 			}
 			c.post = re
 		} else {
-			return nil, errors.New("invalid condition")
+			// Point at the second _
+			second := len(condSplit[0]) + 1 + len(condSplit[1])
+			return nil, errorAt(at+second, errors.New("invalid condition"))
 		}
 		if conditions == nil {
 			conditions = c
@@ -117,3 +277,38 @@ func (s *Scago) ParseCondition(input string) (*Condition, error) {
 	}
 	return conditions, nil
 }
+
+// syllableStresses are the stress conditions, along with the stresses
+// of the target's syllable that each is met by.
+var syllableStresses = map[string][]Stress{
+	"stressed":   {Primary, Secondary},
+	"unstressed": {Unstressed},
+	"primary":    {Primary},
+	"secondary":  {Secondary},
+}
+
+// parseSyllableCondition sets c to check the position of the target
+// in its syllable, which syllable it is in, or the stress of its
+// syllable, as given by a condition such as {onset}, {-1} or
+// {stressed}.
+func (s *Scago) parseSyllableCondition(c *Condition, input string) error {
+	if s.segmenter == nil || s.segmenter.template == nil {
+		return errors.New("syllable condition used without a syllable template")
+	}
+	if stresses, ok := syllableStresses[input]; ok {
+		c.stresses = stresses
+		return nil
+	}
+	for _, p := range []SyllablePosition{Onset, Nucleus, Coda} {
+		if input == p.String() {
+			c.position = p
+			return nil
+		}
+	}
+	n, err := strconv.Atoi(input)
+	if err != nil || n == 0 {
+		return fmt.Errorf("invalid syllable condition {%s}", input)
+	}
+	c.syllable = n
+	return nil
+}
@@ -44,6 +44,39 @@ func TestExpandPatternToRegex(t *testing.T) {
 		}
 		assert.Equal(got.String(), "^xy(a|b|c)z$")
 	})
+	t.Run("Expand optional and repeated elements", func(t *testing.T) {
+		assert := assert.New(t)
+		got, err := s.ExpandPatternToRegex("x(yK)z+", false, false)
+		if !assert.NoError(err) {
+			return
+		}
+		assert.Equal(got.String(), "x(?:y(a|b|c))?(?:z)+")
+	})
+	t.Run("Expand segments with a meaning in regexps", func(t *testing.T) {
+		assert := assert.New(t)
+		got, err := s.ExpandPatternToRegex("x.y?|", false, false)
+		if !assert.NoError(err) {
+			return
+		}
+		assert.Equal(got.String(), `x\.y\?\|`)
+		r, err := s.NewRule("a > e / ._")
+		if !assert.NoError(err) {
+			return
+		}
+		result, err := r.Apply("əa")
+		assert.NoError(err)
+		assert.Equal(result, "əa")
+		result, err = r.Apply(".a")
+		assert.NoError(err)
+		assert.Equal(result, ".e")
+	})
+	t.Run("Expand invalid patterns", func(t *testing.T) {
+		assert := assert.New(t)
+		for _, pattern := range []string{"x(y", "x)y", "x()", "+x", "x[y"} {
+			_, err := s.ExpandPatternToRegex(pattern, false, false)
+			assert.Error(err, pattern)
+		}
+	})
 }
 
 func TestParseCondition(t *testing.T) {
@@ -0,0 +1,113 @@
+// Package convert holds what is shared between the packages that
+// translate rulesets written for other sound change appliers into
+// scago categories and rules.
+package convert
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Problem describes a line of a ruleset that could not be translated
+// into scago, or could only be translated approximately.
+type Problem struct {
+	Line    int    // the line of the ruleset, starting from 1
+	Text    string // the line as it was written
+	Reason  string // why the line could not be translated exactly
+	Skipped bool   // true if nothing was added to the Scago for the line
+}
+
+// Error returns the problem as a string.
+func (p Problem) Error() string {
+	if p.Skipped {
+		return fmt.Sprintf("line %d: skipped %q: %s", p.Line, p.Text, p.Reason)
+	}
+	return fmt.Sprintf("line %d: approximated %q: %s", p.Line, p.Text, p.Reason)
+}
+
+// Symbols hands out the single-character identifiers that scago needs
+// for categories to be usable in conditions, for formats whose
+// categories have longer names. Characters that appear anywhere in the
+// ruleset being translated are never handed out, so an identifier can
+// never be mistaken for a sound.
+type Symbols struct {
+	used  map[rune]bool     // characters that can't be used as identifiers
+	names map[string]string // identifiers handed out so far, by name
+}
+
+// symbolCandidates are the characters tried, in order, as identifiers.
+const symbolCandidates = "ABCDEFGHIJKLMNOPQRSTUVWXYZΓΔΘΛΞΠΣΦΨΩБГДЖЗИЛПФЦЧШЩЭЮЯ"
+
+// NewSymbols returns a new Symbols that won't hand out any character
+// appearing in the given text, which should be the whole ruleset.
+func NewSymbols(text string) *Symbols {
+	used := make(map[rune]bool)
+	for _, r := range text {
+		used[r] = true
+	}
+	for _, r := range "#_>/!@,;=()[]{}" {
+		used[r] = true
+	}
+	return &Symbols{used, make(map[string]string)}
+}
+
+// Get returns the identifier for the category with the given name,
+// handing out a new one if the name hasn't been seen before. A name
+// that is already a single character is used as it is.
+// Returns an error if there are no identifiers left.
+func (s *Symbols) Get(name string) (string, error) {
+	if id, ok := s.names[name]; ok {
+		return id, nil
+	}
+	if utf8.RuneCountInString(name) == 1 {
+		s.names[name] = name
+		return name, nil
+	}
+	for _, r := range symbolCandidates {
+		if !s.used[r] {
+			s.used[r] = true
+			s.names[name] = string(r)
+			return string(r), nil
+		}
+	}
+	return "", fmt.Errorf("no identifier left for category %q", name)
+}
+
+// Lookup returns the identifier handed out for the category with the
+// given name, and false if there isn't one.
+func (s *Symbols) Lookup(name string) (string, bool) {
+	id, ok := s.names[name]
+	return id, ok
+}
+
+// Literal returns the given sound or sequence of sounds escaped so
+// that scago matches it literally in a target. Conditions and
+// exceptions already match plain sounds literally, so they need no
+// escaping.
+func Literal(sounds string) string {
+	return regexp.QuoteMeta(sounds)
+}
+
+// Rule returns a rule in scago notation from its parts, leaving out
+// any parts that are not needed. Flags are appended after a semicolon.
+func Rule(target, change, condition, exception string, flags []string) string {
+	sb := &strings.Builder{}
+	sb.WriteString(target)
+	sb.WriteString(" > ")
+	sb.WriteString(change)
+	if condition != "" || exception != "" {
+		sb.WriteString(" / ")
+		sb.WriteString(condition)
+	}
+	if exception != "" {
+		sb.WriteString(" ! ")
+		sb.WriteString(exception)
+	}
+	if len(flags) > 0 {
+		sb.WriteString(" ; ")
+		sb.WriteString(strings.Join(flags, ", "))
+	}
+	return strings.TrimSpace(sb.String())
+}
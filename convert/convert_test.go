@@ -0,0 +1,43 @@
+package convert
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSymbols(t *testing.T) {
+	assert := assert.New(t)
+	s := NewSymbols("ABba > c / V_D")
+	got, err := s.Get("vowel")
+	assert.NoError(err)
+	assert.Equal(got, "C")
+	got, err = s.Get("stop")
+	assert.NoError(err)
+	assert.Equal(got, "E")
+	got, err = s.Get("vowel")
+	assert.NoError(err)
+	assert.Equal(got, "C")
+	got, err = s.Get("N")
+	assert.NoError(err)
+	assert.Equal(got, "N")
+	got, ok := s.Lookup("stop")
+	assert.True(ok)
+	assert.Equal(got, "E")
+	_, ok = s.Lookup("nasal")
+	assert.False(ok)
+}
+
+func TestRule(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(Rule("a", "e", "", "", nil), "a > e")
+	assert.Equal(Rule("a", "", "_#", "", nil), "a >  / _#")
+	assert.Equal(Rule("", "e", "_#", "b_", []string{"rtl", "repeat"}), "> e / _# ! b_ ; rtl, repeat")
+	assert.Equal(Literal("a.b"), `a\.b`)
+}
+
+func TestProblem(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(Problem{3, "a(b", "no", true}.Error(), `line 3: skipped "a(b": no`)
+	assert.Equal(Problem{4, "x", "close", false}.Error(), `line 4: approximated "x": close`)
+}
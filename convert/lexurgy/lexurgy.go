@@ -0,0 +1,354 @@
+// Package lexurgy translates rulesets written for Lexurgy into scago
+// categories and rules.
+//
+// The following Lexurgy constructs are translated:
+//
+//	Class vowel {a, e, i}       classes, which become categories
+//	name:                       rules, with the propagate, ltr and rtl
+//	                            modifiers
+//	a => e / @vowel _ $         expressions, with an optional exception
+//	                            after //
+//	{p, t} => {b, d}            lists, which become categories
+//	* => e / _ $                insertion and deletion with *
+//	# comment                   comments
+//
+// Since scago category identifiers are single characters, each class
+// and list is given an identifier that doesn't otherwise appear in the
+// ruleset. Anything else, such as features, syllables, romanizers or
+// optional elements, is reported as a convert.Problem and skipped.
+// Since Lexurgy applies an expression to every match at once unless
+// the rule has the ltr or rtl modifier, such rules are given the
+// simultaneous flag. A rule with several expressions is translated as
+// one scago rule per expression, applied one after another, which is
+// reported as a convert.Problem but not skipped.
+package lexurgy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"go.m5ka.dev/scago"
+	"go.m5ka.dev/scago/convert"
+)
+
+// blocks are the Lexurgy declarations with an indented body that
+// can't be translated.
+var blocks = []string{"Syllables", "Deromanizer", "Romanizer"}
+
+// declarations are the single-line Lexurgy declarations that can't be
+// translated.
+var declarations = []string{"Feature", "Symbol", "Diacritic", "Element"}
+
+// unsupported are the characters with a meaning in Lexurgy expressions
+// that scago has no equivalent for.
+const unsupported = ".()?+*[]!&~"
+
+// converter holds the state of a translation in progress.
+type converter struct {
+	s           *scago.Scago
+	symbols     *convert.Symbols
+	flags       []string // flags of the rule being translated
+	expressions int      // number of expressions in the rule so far
+	block       string   // the untranslatable block being skipped, if any
+	problems    []convert.Problem
+}
+
+// Load reads a Lexurgy ruleset from r and adds its classes and rules
+// to s. Lines that can't be translated are skipped and returned as
+// problems, rather than stopping the translation. Returns an error only
+// if r could not be read.
+func Load(s *scago.Scago, r io.Reader) ([]convert.Problem, error) {
+	input, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	text := string(input)
+	c := &converter{s: s, symbols: convert.NewSymbols(text)}
+	for i, line := range strings.Split(text, "\n") {
+		approximation, err := c.line(line)
+		if err != nil {
+			c.problem(i+1, line, err.Error(), true)
+		} else if approximation != "" {
+			c.problem(i+1, line, approximation, false)
+		}
+	}
+	return c.problems, nil
+}
+
+// problem records a problem with the given line.
+func (c *converter) problem(n int, line string, reason string, skipped bool) {
+	c.problems = append(c.problems, convert.Problem{
+		Line:    n,
+		Text:    strings.TrimSpace(line),
+		Reason:  reason,
+		Skipped: skipped,
+	})
+}
+
+// line translates a single line of a Lexurgy ruleset. If the line
+// could only be translated approximately, the reason is returned.
+func (c *converter) line(line string) (string, error) {
+	indented := line != "" && unicode.IsSpace(rune(line[0]))
+	if i := strings.Index(line, "#"); i >= 0 {
+		line = line[:i]
+	}
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return "", nil
+	}
+	if c.block != "" {
+		if indented {
+			return "", fmt.Errorf("part of %s, which can't be translated", c.block)
+		}
+		c.block = ""
+	}
+	keyword, rest, _ := strings.Cut(line, " ")
+	switch {
+	case keyword == "Class":
+		return "", c.class(rest)
+	case contains(declarations, keyword):
+		return "", fmt.Errorf("%s declarations can't be translated", keyword)
+	case contains(blocks, strings.TrimSuffix(keyword, ":")):
+		c.block = strings.TrimSuffix(keyword, ":")
+		return "", fmt.Errorf("%s can't be translated", c.block)
+	case line == "then:":
+		// Sub-rules chained with then: apply one after another, which
+		// is what scago does anyway
+		return "", nil
+	case strings.HasSuffix(line, ":") && !strings.Contains(line, "=>"):
+		return c.rule(strings.TrimSuffix(line, ":"))
+	case strings.Contains(line, "=>"):
+		return c.expression(line)
+	default:
+		return "", errors.New("line is not a class, rule or expression")
+	}
+}
+
+// contains returns true if list contains s.
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// class translates a class declaration such as vowel {a, e, i}.
+func (c *converter) class(input string) error {
+	name, members, ok := strings.Cut(input, " ")
+	members = strings.TrimSpace(members)
+	if !ok || !strings.HasPrefix(members, "{") || !strings.HasSuffix(members, "}") {
+		return errors.New("class must be written Class name {sounds}")
+	}
+	sounds, err := c.members(members)
+	if err != nil {
+		return err
+	}
+	id, err := c.symbols.Get("@" + name)
+	if err != nil {
+		return err
+	}
+	return c.s.AddCategory(id, sounds)
+}
+
+// members returns the sounds in a list such as {a, b, @class}.
+func (c *converter) members(list string) ([]string, error) {
+	var sounds []string
+	for _, member := range strings.Split(list[1:len(list)-1], ",") {
+		member = strings.TrimSpace(member)
+		if strings.HasPrefix(member, "@") {
+			category, err := c.category(member)
+			if err != nil {
+				return nil, err
+			}
+			sounds = append(sounds, c.s.GetCategory(category).Sounds()...)
+			continue
+		}
+		if member == "" || strings.ContainsAny(member, unsupported) {
+			return nil, fmt.Errorf("list member %q can't be translated", member)
+		}
+		sounds = append(sounds, strings.Join(strings.Fields(member), ""))
+	}
+	return sounds, nil
+}
+
+// category returns the identifier of the category for a class
+// reference such as @vowel, or for a list such as {a, e}, adding a
+// category for the list if it doesn't have one yet.
+func (c *converter) category(element string) (string, error) {
+	if strings.HasPrefix(element, "@") {
+		if id, ok := c.symbols.Lookup(element); ok {
+			return id, nil
+		}
+		return "", fmt.Errorf("class %s is not defined", element)
+	}
+	sounds, err := c.members(element)
+	if err != nil {
+		return "", err
+	}
+	key := "{" + strings.Join(sounds, ",") + "}"
+	if id, ok := c.symbols.Lookup(key); ok {
+		return id, nil
+	}
+	id, err := c.symbols.Get(key)
+	if err != nil {
+		return "", err
+	}
+	return id, c.s.AddCategory(id, sounds)
+}
+
+// rule starts translating the rule with the given name and modifiers,
+// e.g "vowel-harmony propagate".
+func (c *converter) rule(header string) (string, error) {
+	c.flags = nil
+	c.expressions = 0
+	var ignored []string
+	// Lexurgy applies an expression to every match at once unless it
+	// is told to go from one end of the word to the other
+	simultaneous := true
+	for _, modifier := range strings.Fields(header)[1:] {
+		switch modifier {
+		case "propagate":
+			c.flags = append(c.flags, "repeat")
+		case "ltr", "rtl":
+			c.flags = append(c.flags, modifier)
+			simultaneous = false
+		default:
+			ignored = append(ignored, modifier)
+		}
+	}
+	if simultaneous {
+		c.flags = append(c.flags, "simultaneous")
+	}
+	if len(ignored) > 0 {
+		return "modifiers " + strings.Join(ignored, ", ") + " can't be translated and were ignored", nil
+	}
+	return "", nil
+}
+
+// expression translates an expression such as a => e / @vowel _.
+func (c *converter) expression(line string) (string, error) {
+	target, rest, _ := strings.Cut(line, "=>")
+	rest, exception, _ := strings.Cut(rest, "//")
+	change, condition, _ := strings.Cut(rest, "/")
+	t, err := c.target(tokenize(target))
+	if err != nil {
+		return "", err
+	}
+	ch, err := c.change(tokenize(change))
+	if err != nil {
+		return "", err
+	}
+	cond, err := c.environment(tokenize(condition))
+	if err != nil {
+		return "", err
+	}
+	exc, err := c.environment(tokenize(exception))
+	if err != nil {
+		return "", err
+	}
+	if err := c.s.AddRule(convert.Rule(t, ch, cond, exc, c.flags)); err != nil {
+		return "", err
+	}
+	c.expressions++
+	if c.expressions > 1 {
+		return "translated as a separate rule applied after the rule's previous expressions", nil
+	}
+	return "", nil
+}
+
+// tokenize splits part of an expression into its elements, which are
+// separated by spaces, keeping lists such as {a, e} together.
+func tokenize(input string) []string {
+	var tokens []string
+	sb := &strings.Builder{}
+	depth := 0
+	for _, r := range input {
+		switch {
+		case r == '{':
+			depth++
+		case r == '}':
+			depth--
+		case unicode.IsSpace(r) && depth == 0:
+			if sb.Len() > 0 {
+				tokens = append(tokens, sb.String())
+				sb.Reset()
+			}
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	if sb.Len() > 0 {
+		tokens = append(tokens, sb.String())
+	}
+	return tokens
+}
+
+// target translates the target of an expression.
+func (c *converter) target(tokens []string) (string, error) {
+	if len(tokens) == 1 && tokens[0] == "*" {
+		return "", nil
+	}
+	if len(tokens) == 1 && (strings.HasPrefix(tokens[0], "@") || strings.HasPrefix(tokens[0], "{")) {
+		return c.category(tokens[0])
+	}
+	literal, err := c.literal(tokens)
+	if err != nil {
+		return "", err
+	}
+	return convert.Literal(literal), nil
+}
+
+// change translates the change of an expression.
+func (c *converter) change(tokens []string) (string, error) {
+	if len(tokens) == 1 && tokens[0] == "*" {
+		return "", nil
+	}
+	if len(tokens) == 1 && (strings.HasPrefix(tokens[0], "@") || strings.HasPrefix(tokens[0], "{")) {
+		return c.category(tokens[0])
+	}
+	return c.literal(tokens)
+}
+
+// literal returns the sounds in the given tokens as a single string,
+// or an error if any of the tokens is not a plain sequence of sounds.
+func (c *converter) literal(tokens []string) (string, error) {
+	if len(tokens) == 0 {
+		return "", errors.New("missing target or change")
+	}
+	for _, token := range tokens {
+		if strings.ContainsAny(token, unsupported) || strings.HasPrefix(token, "@") || strings.HasPrefix(token, "{") {
+			return "", fmt.Errorf("%q can only be translated on its own in a target or change", token)
+		}
+	}
+	return strings.Join(tokens, ""), nil
+}
+
+// environment translates the environment or exception of an
+// expression.
+func (c *converter) environment(tokens []string) (string, error) {
+	sb := &strings.Builder{}
+	for _, token := range tokens {
+		switch {
+		case token == "_":
+			sb.WriteString("_")
+		case token == "$":
+			sb.WriteString("#")
+		case strings.HasPrefix(token, "@") || strings.HasPrefix(token, "{"):
+			id, err := c.category(token)
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(id)
+		case strings.ContainsAny(token, unsupported+"$"):
+			return "", fmt.Errorf("%q can't be translated in an environment", token)
+		default:
+			sb.WriteString(token)
+		}
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,124 @@
+package lexurgy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.m5ka.dev/scago"
+)
+
+func TestLoad(t *testing.T) {
+	assert := assert.New(t)
+	s := scago.New()
+	problems, err := Load(s, strings.NewReader(`# a small Lexurgy ruleset
+Class vowel {a, e, i, o, u}
+Class stop {p, t, k}
+Feature voice
+
+Syllables:
+    @stop? @vowel
+
+intervocalic-voicing:
+    @stop => {b, d, g} / @vowel _ @vowel
+
+final-loss:
+    @vowel => * / _ $ // k _
+
+raising propagate:
+    e => i / _ i
+    o => u / _ u
+
+unknown defer:
+    a => ə / _ (k)
+`))
+	if !assert.NoError(err) {
+		return
+	}
+	var lines []int
+	var skipped []bool
+	for _, p := range problems {
+		lines = append(lines, p.Line)
+		skipped = append(skipped, p.Skipped)
+	}
+	assert.Equal(lines, []int{4, 6, 7, 17, 19, 20})
+	assert.Equal(skipped, []bool{true, true, true, false, false, true})
+	got, err := s.Apply("apata")
+	assert.NoError(err)
+	assert.Equal(got, "abad")
+	got, err = s.Apply("tki")
+	assert.NoError(err)
+	assert.Equal(got, "tki")
+	got, err = s.Apply("teeik")
+	assert.NoError(err)
+	assert.Equal(got, "tiiik")
+}
+
+func TestLoadModifiers(t *testing.T) {
+	assert := assert.New(t)
+	s := scago.New()
+	_, err := Load(s, strings.NewReader(`lengthening:
+    a => aa
+
+fronting ltr:
+    a => e / _ e
+
+dissimilation:
+    a => b / a _
+`))
+	if !assert.NoError(err) {
+		return
+	}
+	rules := s.Rules()
+	if !assert.Len(rules, 3) {
+		return
+	}
+	assert.Equal(rules[0].String(), "a > aa ; simultaneous")
+	assert.Equal(rules[1].String(), "a > e / _e")
+	assert.Equal(rules[2].String(), "a > b / a_ ; simultaneous")
+	got, err := s.Apply("apa")
+	assert.NoError(err)
+	assert.Equal(got, "abpab")
+	got, err = s.Apply("aa")
+	assert.NoError(err)
+	assert.Equal(got, "abbb")
+}
+
+func TestLoadWordFinalInsertion(t *testing.T) {
+	assert := assert.New(t)
+	s := scago.New()
+	_, err := Load(s, strings.NewReader(`epenthesis:
+    * => e / _ $
+`))
+	if !assert.NoError(err) {
+		return
+	}
+	got, err := s.Apply("pat")
+	assert.NoError(err)
+	assert.Equal(got, "pate")
+}
+
+func TestLoadEnvironmentLiterals(t *testing.T) {
+	assert := assert.New(t)
+	s := scago.New()
+	_, err := Load(s, strings.NewReader(`marked-raising:
+    a => e / _ ^
+`))
+	if !assert.NoError(err) {
+		return
+	}
+	rules := s.Rules()
+	if !assert.Len(rules, 1) {
+		return
+	}
+	assert.Equal(rules[0].String(), "a > e / _^ ; simultaneous")
+	got, err := s.Apply("ta^ka")
+	assert.NoError(err)
+	assert.Equal(got, "te^ka")
+}
+
+func TestTokenize(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(tokenize(" @vowel _ {a, e} $ "), []string{"@vowel", "_", "{a, e}", "$"})
+	assert.Nil(tokenize("  "))
+}
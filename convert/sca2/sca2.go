@@ -0,0 +1,244 @@
+// Package sca2 translates rulesets written for Zompist's SCA² into
+// scago categories and rules.
+//
+// The following SCA² constructs are translated:
+//
+//	V=aeiou          categories, one character per sound
+//	ch|č             rewrite rules whose left side is a multigraph,
+//	                 which become scago segments
+//	a/e/_#           sound changes, also written a→e/_# or a>e/_#
+//	a/e/_#/b_        sound changes with an exception
+//	[ptk]/b/V_V      nonce categories in the target
+//	* comment        comments
+//
+// Anything else, such as optional or wildcard elements in
+// environments, gemination, metathesis or rewrites of single
+// characters, is reported as a convert.Problem and skipped.
+package sca2
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"go.m5ka.dev/scago"
+	"go.m5ka.dev/scago/convert"
+)
+
+// unsupported are the characters with a meaning in SCA² environments
+// that scago has no equivalent for.
+const unsupported = "()[]…~²%"
+
+// converter holds the state of a translation in progress.
+type converter struct {
+	s          *scago.Scago
+	categories map[string]bool // identifiers of the categories defined so far
+	rewrites   []string        // pairs of a character and the multigraph it stands for, in the order declared
+	problems   []convert.Problem
+}
+
+// Load reads an SCA² ruleset from r and adds its categories and rules
+// to s. Lines that can't be translated are skipped and returned as
+// problems, rather than stopping the translation. Returns an error only
+// if r could not be read.
+func Load(s *scago.Scago, r io.Reader) ([]convert.Problem, error) {
+	c := &converter{s, make(map[string]bool), nil, nil}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if err := c.line(line); err != nil {
+			c.problems = append(c.problems, convert.Problem{
+				Line:    n,
+				Text:    line,
+				Reason:  err.Error(),
+				Skipped: true,
+			})
+		}
+	}
+	return c.problems, scanner.Err()
+}
+
+// line translates a single line of an SCA² ruleset.
+func (c *converter) line(line string) error {
+	switch {
+	case line == "" || strings.HasPrefix(line, "*"):
+		return nil
+	case strings.Contains(line, "|"):
+		return c.rewrite(line)
+	case isCategory(line):
+		return c.category(line)
+	default:
+		return c.rule(line)
+	}
+}
+
+// isCategory returns true if the line is a category definition, i.e a
+// single character followed by =.
+func isCategory(line string) bool {
+	name, _, ok := strings.Cut(line, "=")
+	return ok && utf8.RuneCountInString(strings.TrimSpace(name)) == 1 && !strings.ContainsAny(line, "/→>")
+}
+
+// category translates a category definition such as V=aeiou.
+func (c *converter) category(line string) error {
+	name, sounds, _ := strings.Cut(line, "=")
+	name = strings.TrimSpace(name)
+	var list []string
+	for _, r := range strings.TrimSpace(sounds) {
+		sound := string(r)
+		if c.categories[sound] {
+			// A category used inside another is expanded in place
+			list = append(list, c.s.GetCategory(sound).Sounds()...)
+			continue
+		}
+		list = append(list, c.unrewrite(sound))
+	}
+	if len(list) == 0 {
+		return errors.New("category has no sounds")
+	}
+	if err := c.s.AddCategory(name, list); err != nil {
+		return err
+	}
+	c.categories[name] = true
+	return nil
+}
+
+// rewrite translates a rewrite rule such as ch|č. Since SCA² rewrites
+// are mostly used to let a multigraph be treated as one sound, these
+// become scago segments, and the character standing for the multigraph
+// is put back wherever it is used later in the ruleset.
+func (c *converter) rewrite(line string) error {
+	from, to, _ := strings.Cut(line, "|")
+	from = strings.TrimSpace(from)
+	to = strings.TrimSpace(to)
+	if utf8.RuneCountInString(from) < 2 || utf8.RuneCountInString(to) != 1 {
+		return errors.New("only rewrites of a multigraph to a single character can be translated")
+	}
+	if err := c.s.AddSegments([]string{from}); err != nil {
+		return err
+	}
+	// A character rewritten again stands for the latest multigraph
+	for i := 0; i < len(c.rewrites); i += 2 {
+		if c.rewrites[i] == to {
+			c.rewrites[i+1] = from
+			return nil
+		}
+	}
+	c.rewrites = append(c.rewrites, to, from)
+	return nil
+}
+
+// unrewrite puts the multigraphs back in place of the characters that
+// stand for them in the given string, in a single pass so that a
+// multigraph put back is never itself rewritten.
+func (c *converter) unrewrite(input string) string {
+	return strings.NewReplacer(c.rewrites...).Replace(input)
+}
+
+// rule translates a sound change such as a/e/_# into a scago rule.
+func (c *converter) rule(line string) error {
+	// SCA² also allows target→replacement/environment
+	for _, arrow := range []string{"→", ">"} {
+		if strings.Contains(line, arrow) {
+			line = strings.Replace(line, arrow, "/", 1)
+			break
+		}
+	}
+	parts := strings.Split(line, "/")
+	if len(parts) < 3 || len(parts) > 4 {
+		return errors.New("sound change must be target/replacement/environment with an optional /exception")
+	}
+	target, err := c.target(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return err
+	}
+	change, err := c.replacement(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return err
+	}
+	condition, err := c.environment(strings.TrimSpace(parts[2]))
+	if err != nil {
+		return err
+	}
+	var exception string
+	if len(parts) == 4 {
+		exception, err = c.environment(strings.TrimSpace(parts[3]))
+		if err != nil {
+			return err
+		}
+	}
+	return c.s.AddRule(convert.Rule(target, change, condition, exception, nil))
+}
+
+// target translates the target of a sound change.
+func (c *converter) target(input string) (string, error) {
+	if c.categories[input] {
+		return input, nil
+	}
+	// A nonce category becomes a list of targets
+	if strings.HasPrefix(input, "[") && strings.HasSuffix(input, "]") {
+		var targets []string
+		for _, r := range input[1 : len(input)-1] {
+			if c.categories[string(r)] {
+				targets = append(targets, string(r))
+			} else {
+				targets = append(targets, convert.Literal(c.unrewrite(string(r))))
+			}
+		}
+		return strings.Join(targets, ","), nil
+	}
+	if err := c.checkLiteral(input); err != nil {
+		return "", err
+	}
+	return convert.Literal(c.unrewrite(input)), nil
+}
+
+// replacement translates the replacement of a sound change.
+func (c *converter) replacement(input string) (string, error) {
+	if c.categories[input] {
+		return input, nil
+	}
+	if strings.Contains(input, "\\") {
+		return "", errors.New("metathesis can't be translated")
+	}
+	if err := c.checkLiteral(input); err != nil {
+		return "", err
+	}
+	return c.unrewrite(input), nil
+}
+
+// checkLiteral returns an error if the given target or replacement is
+// not a plain sequence of sounds.
+func (c *converter) checkLiteral(input string) error {
+	for _, r := range input {
+		if c.categories[string(r)] {
+			return errors.New("categories can only be used on their own in a target or replacement")
+		}
+		if strings.ContainsRune(unsupported, r) {
+			return errors.New("unsupported character " + string(r) + " in target or replacement")
+		}
+	}
+	return nil
+}
+
+// environment translates the environment or exception of a sound
+// change.
+func (c *converter) environment(input string) (string, error) {
+	if strings.Count(input, "_") != 1 {
+		return "", errors.New("environment must contain exactly one _")
+	}
+	sb := &strings.Builder{}
+	for _, r := range input {
+		switch {
+		case strings.ContainsRune(unsupported, r):
+			return "", errors.New("unsupported character " + string(r) + " in environment")
+		case r == '_' || r == '#' || c.categories[string(r)]:
+			sb.WriteRune(r)
+		default:
+			sb.WriteString(c.unrewrite(string(r)))
+		}
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,78 @@
+package sca2
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.m5ka.dev/scago"
+)
+
+func TestLoad(t *testing.T) {
+	assert := assert.New(t)
+	s := scago.New()
+	problems, err := Load(s, strings.NewReader(`
+* a small SCA² ruleset
+ch|č
+V=aeiou
+P=ptkč
+B=bdgj
+P/B/V_V
+[ei]/i/_#/č_
+a//_č
+o/u/(V)_
+p/f/_#/s…_
+`))
+	if !assert.NoError(err) {
+		return
+	}
+	if assert.Len(problems, 2) {
+		assert.Equal(problems[0].Line, 10)
+		assert.True(problems[0].Skipped)
+		assert.Equal(problems[1].Line, 11)
+		assert.True(problems[1].Skipped)
+	}
+	assert.Equal(s.GetCategory("P").Sounds(), []string{"p", "t", "k", "ch"})
+	got, err := s.Apply("achapo")
+	assert.NoError(err)
+	assert.Equal(got, "ajabo")
+	got, err = s.Apply("pate")
+	assert.NoError(err)
+	assert.Equal(got, "padi")
+	got, err = s.Apply("tche")
+	assert.NoError(err)
+	assert.Equal(got, "tche")
+	got, err = s.Apply("tach")
+	assert.NoError(err)
+	assert.Equal(got, "tch")
+}
+
+func TestLoadEnvironmentLiterals(t *testing.T) {
+	assert := assert.New(t)
+	s := scago.New()
+	_, err := Load(s, strings.NewReader("a/e/_.\n"))
+	if !assert.NoError(err) {
+		return
+	}
+	rules := s.Rules()
+	if !assert.Len(rules, 1) {
+		return
+	}
+	assert.Equal(rules[0].String(), "a > e / _.")
+	got, err := s.Apply("ta.ka")
+	assert.NoError(err)
+	assert.Equal(got, "te.ka")
+}
+
+func TestUnrewrite(t *testing.T) {
+	assert := assert.New(t)
+	c := &converter{s: scago.New()}
+	for _, rewrite := range []string{"ch|č", "čh|x", "sh|š", "zh|š"} {
+		assert.NoError(c.rewrite(rewrite))
+	}
+	// Each character is put back once, even where the multigraph put
+	// back contains a character that stands for another
+	for i := 0; i < 10; i++ {
+		assert.Equal(c.unrewrite("xčša"), "čhchzha")
+	}
+}
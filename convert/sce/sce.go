@@ -0,0 +1,271 @@
+// Package sce translates rulesets written for KathTheDragon's SCE into
+// scago categories and rules.
+//
+// The following SCE constructs are translated:
+//
+//	V = a, e, [C]               categories, which may include other
+//	                            categories
+//	a > e / [V]_#               rules, with categories written in
+//	                            brackets
+//	p, t, k > b, d, g           lists of targets and changes of the same
+//	                            length, which become categories
+//	a > e / _# ! b_             exceptions
+//	// comment                  comments
+//
+// Since scago category identifiers are single characters, each
+// category and list is given an identifier that doesn't otherwise
+// appear in the ruleset. Anything else, such as wildcards, optional
+// elements, several environments for a rule, or indexed targets, is
+// reported as a convert.Problem and skipped.
+package sce
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"go.m5ka.dev/scago"
+	"go.m5ka.dev/scago/convert"
+)
+
+// unsupported are the characters with a meaning in SCE rules that
+// scago has no equivalent for.
+const unsupported = "*(){}$%~@&|+?"
+
+// converter holds the state of a translation in progress.
+type converter struct {
+	s        *scago.Scago
+	symbols  *convert.Symbols
+	problems []convert.Problem
+}
+
+// Load reads an SCE ruleset from r and adds its categories and rules
+// to s. Lines that can't be translated are skipped and returned as
+// problems, rather than stopping the translation. Returns an error only
+// if r could not be read.
+func Load(s *scago.Scago, r io.Reader) ([]convert.Problem, error) {
+	input, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	text := string(input)
+	c := &converter{s: s, symbols: convert.NewSymbols(text)}
+	for i, line := range strings.Split(text, "\n") {
+		if j := strings.Index(line, "//"); j >= 0 {
+			line = line[:j]
+		}
+		line = strings.TrimSpace(line)
+		if err := c.line(line); err != nil {
+			c.problems = append(c.problems, convert.Problem{
+				Line:    i + 1,
+				Text:    line,
+				Reason:  err.Error(),
+				Skipped: true,
+			})
+		}
+	}
+	return c.problems, nil
+}
+
+// line translates a single line of an SCE ruleset.
+func (c *converter) line(line string) error {
+	switch {
+	case line == "":
+		return nil
+	case strings.Contains(line, ">"):
+		return c.rule(line)
+	case strings.Contains(line, "="):
+		return c.define(line)
+	default:
+		return errors.New("line is neither a rule nor a category definition")
+	}
+}
+
+// define translates a category definition such as V = a, e, i.
+func (c *converter) define(line string) error {
+	name, members, _ := strings.Cut(line, "=")
+	name = strings.TrimSpace(name)
+	if name == "" || strings.ContainsAny(name, "+-") {
+		return errors.New("only plain category definitions can be translated")
+	}
+	sounds, err := c.members(strings.Split(members, ","))
+	if err != nil {
+		return err
+	}
+	if len(sounds) == 0 {
+		return errors.New("category has no sounds")
+	}
+	id, err := c.symbols.Get("[" + name + "]")
+	if err != nil {
+		return err
+	}
+	return c.s.AddCategory(id, sounds)
+}
+
+// members returns the sounds in a list of category members, which
+// may include other categories.
+func (c *converter) members(list []string) ([]string, error) {
+	var sounds []string
+	for _, member := range list {
+		member = strings.TrimSpace(member)
+		if isCategory(member) {
+			id, err := c.category(member)
+			if err != nil {
+				return nil, err
+			}
+			sounds = append(sounds, c.s.GetCategory(id).Sounds()...)
+			continue
+		}
+		if strings.ContainsAny(member, unsupported+"[]") {
+			return nil, fmt.Errorf("%q can't be translated", member)
+		}
+		if member != "" {
+			sounds = append(sounds, member)
+		}
+	}
+	return sounds, nil
+}
+
+// isCategory returns true if the given element is a category
+// reference such as [V].
+func isCategory(element string) bool {
+	return strings.HasPrefix(element, "[") && strings.HasSuffix(element, "]") && strings.Count(element, "[") == 1
+}
+
+// category returns the identifier of the category for a reference
+// such as [V].
+func (c *converter) category(reference string) (string, error) {
+	if id, ok := c.symbols.Lookup(reference); ok {
+		return id, nil
+	}
+	return "", fmt.Errorf("category %s is not defined", reference)
+}
+
+// list returns the identifier of a category made up of the given
+// sounds, adding one if it doesn't exist yet.
+func (c *converter) list(sounds []string) (string, error) {
+	key := "{" + strings.Join(sounds, ",") + "}"
+	if id, ok := c.symbols.Lookup(key); ok {
+		return id, nil
+	}
+	id, err := c.symbols.Get(key)
+	if err != nil {
+		return "", err
+	}
+	return id, c.s.AddCategory(id, sounds)
+}
+
+// rule translates a rule such as a > e / [V]_ ! #_.
+func (c *converter) rule(line string) error {
+	targets, rest, _ := strings.Cut(line, ">")
+	rest, exception, _ := strings.Cut(rest, "!")
+	changes, condition, _ := strings.Cut(rest, "/")
+	t, ch, err := c.targetAndChange(split(targets), split(changes))
+	if err != nil {
+		return err
+	}
+	cond, err := c.environment(condition)
+	if err != nil {
+		return err
+	}
+	exc, err := c.environment(exception)
+	if err != nil {
+		return err
+	}
+	return c.s.AddRule(convert.Rule(t, ch, cond, exc, nil))
+}
+
+// split splits a comma-separated list of targets or changes, leaving
+// out any that are blank.
+func split(input string) []string {
+	var list []string
+	for _, item := range strings.Split(input, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// targetAndChange translates the targets and changes of a rule. When
+// there are several of each, they are mapped one to one through a
+// pair of categories.
+func (c *converter) targetAndChange(targets []string, changes []string) (string, string, error) {
+	for _, element := range append(append([]string{}, targets...), changes...) {
+		if strings.ContainsAny(element, unsupported) {
+			return "", "", fmt.Errorf("%q can't be translated in a target or change", element)
+		}
+		if strings.ContainsAny(element, "[]") && !isCategory(element) {
+			return "", "", fmt.Errorf("categories can only be used on their own in a target or change, not in %q", element)
+		}
+	}
+	if len(changes) > 1 {
+		if len(changes) != len(targets) {
+			return "", "", errors.New("there must be as many changes as targets")
+		}
+		for _, element := range append(append([]string{}, targets...), changes...) {
+			if isCategory(element) {
+				return "", "", fmt.Errorf("category %s can't be translated in a list of several changes", element)
+			}
+		}
+		from, err := c.list(targets)
+		if err != nil {
+			return "", "", err
+		}
+		to, err := c.list(changes)
+		return from, to, err
+	}
+	var t []string
+	for _, target := range targets {
+		if isCategory(target) {
+			id, err := c.category(target)
+			if err != nil {
+				return "", "", err
+			}
+			t = append(t, id)
+		} else {
+			t = append(t, convert.Literal(target))
+		}
+	}
+	var ch string
+	if len(changes) == 1 {
+		ch = changes[0]
+		if isCategory(ch) {
+			id, err := c.category(ch)
+			if err != nil {
+				return "", "", err
+			}
+			ch = id
+		}
+	}
+	return strings.Join(t, ","), ch, nil
+}
+
+// environment translates the environment or exception of a rule.
+func (c *converter) environment(input string) (string, error) {
+	input = strings.TrimSpace(input)
+	if strings.ContainsAny(input, unsupported) {
+		return "", fmt.Errorf("environment %q can't be translated", input)
+	}
+	sb := &strings.Builder{}
+	for input != "" {
+		if strings.HasPrefix(input, "[") {
+			end := strings.Index(input, "]")
+			if end < 0 {
+				return "", errors.New("unclosed category in environment")
+			}
+			id, err := c.category(input[:end+1])
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(id)
+			input = input[end+1:]
+			continue
+		}
+		r := []rune(input)[0]
+		sb.WriteRune(r)
+		input = input[len(string(r)):]
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,58 @@
+package sce
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.m5ka.dev/scago"
+)
+
+func TestLoad(t *testing.T) {
+	assert := assert.New(t)
+	s := scago.New()
+	problems, err := Load(s, strings.NewReader(`// a small SCE ruleset
+Vowel = a, e, i, o, u
+Stop = p, t, k
+Sonorant = m, n, [Vowel]
+
+p, t, k > b, d, g / [Vowel]_[Vowel]
+[Vowel] > / _# ! [Stop]_
+e > i / _n
+a > o / _(n)
+a > e / _* | #_
+`))
+	if !assert.NoError(err) {
+		return
+	}
+	if assert.Len(problems, 2) {
+		assert.Equal(problems[0].Line, 9)
+		assert.Equal(problems[1].Line, 10)
+	}
+	got, err := s.Apply("apata")
+	assert.NoError(err)
+	assert.Equal(got, "abad")
+	got, err = s.Apply("teki")
+	assert.NoError(err)
+	assert.Equal(got, "teg")
+	got, err = s.Apply("tenki")
+	assert.NoError(err)
+	assert.Equal(got, "tinki")
+}
+
+func TestLoadEnvironmentLiterals(t *testing.T) {
+	assert := assert.New(t)
+	s := scago.New()
+	_, err := Load(s, strings.NewReader("a > e / _.\n"))
+	if !assert.NoError(err) {
+		return
+	}
+	rules := s.Rules()
+	if !assert.Len(rules, 1) {
+		return
+	}
+	assert.Equal(rules[0].String(), "a > e / _.")
+	got, err := s.Apply("ta.ka")
+	assert.NoError(err)
+	assert.Equal(got, "te.ka")
+}
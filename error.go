@@ -0,0 +1,122 @@
+package scago
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// RuleField is the part of a rule in which a parse error was found.
+type RuleField string
+
+const (
+	FieldRule        RuleField = "rule"        // the rule as a whole
+	FieldTarget      RuleField = "target"      // the target, before >
+	FieldChange      RuleField = "change"      // the change, after >
+	FieldCondition   RuleField = "condition"   // the condition, after /
+	FieldException   RuleField = "exception"   // the exception, after !
+	FieldAlternative RuleField = "alternative" // the alternative, after the exception's /
+	FieldFlags       RuleField = "flags"       // the flags, after ;
+)
+
+// ParseError is returned when a rule could not be parsed. It says
+// which part of the rule is at fault and where in the rule it is, so
+// that the error can be pointed out to the user.
+type ParseError struct {
+	Rule   string    // the rule as it was written
+	Line   int       // the line of the ruleset the rule is on, or 0 if not from a ruleset
+	Field  RuleField // the part of the rule that could not be parsed
+	Offset int       // the offset in characters from the start of the rule to the error
+	Reason string    // a human-readable description of the error
+	Err    error     // the underlying error, if any
+}
+
+// Error returns the error as a string.
+func (e *ParseError) Error() string {
+	sb := &strings.Builder{}
+	if e.Line > 0 {
+		fmt.Fprintf(sb, "line %d, ", e.Line)
+	}
+	if e.Field == FieldRule {
+		fmt.Fprintf(sb, "column %d: invalid rule %q: %s", e.Offset+1, e.Rule, e.Reason)
+	} else {
+		fmt.Fprintf(sb, "column %d: invalid %s in rule %q: %s", e.Offset+1, e.Field, e.Rule, e.Reason)
+	}
+	return sb.String()
+}
+
+// Unwrap returns the underlying error, if any.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// offsetError is an error found partway through the text of a field
+// of a rule, which newParseError points to rather than to the start of
+// the field. Since whitespace is ignored in most fields, the offset
+// counts only the bytes in the field that aren't whitespace.
+type offsetError struct {
+	offset int   // the number of bytes before the error that aren't whitespace
+	err    error // the error itself
+}
+
+// Error returns the error as a string.
+func (e *offsetError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the error itself.
+func (e *offsetError) Unwrap() error {
+	return e.err
+}
+
+// errorAt returns err as an offsetError found offset bytes (that
+// aren't whitespace) into the text being parsed. If err is already an
+// offsetError, found in a part of that text, offset is added to it.
+func errorAt(offset int, err error) error {
+	if oe, ok := err.(*offsetError); ok {
+		return &offsetError{offset + oe.offset, oe.err}
+	}
+	return &offsetError{offset, err}
+}
+
+// countNonSpace returns the number of bytes in s that aren't
+// whitespace.
+func countNonSpace(s string) int {
+	n := 0
+	for _, r := range s {
+		if !unicode.IsSpace(r) {
+			n += utf8.RuneLen(r)
+		}
+	}
+	return n
+}
+
+// newParseError returns a ParseError for the given rule, giving the
+// error as being in the given field, offset bytes into the rule. Any
+// whitespace at the offset is skipped so that the error points to the
+// start of the field's contents, or if err is an offsetError, to the
+// character it was found at.
+func newParseError(rule string, field RuleField, offset int, err error) *ParseError {
+	skip := 0
+	if oe, ok := err.(*offsetError); ok {
+		skip, err = oe.offset, oe.err
+	}
+	for offset < len(rule) {
+		r, size := utf8.DecodeRuneInString(rule[offset:])
+		if !unicode.IsSpace(r) {
+			if skip <= 0 {
+				break
+			}
+			skip -= size
+		}
+		offset += size
+	}
+	return &ParseError{
+		Rule:   rule,
+		Field:  field,
+		Offset: utf8.RuneCountInString(rule[:offset]),
+		Reason: err.Error(),
+		Err:    err,
+	}
+}
@@ -0,0 +1,72 @@
+package scago
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseError(t *testing.T) {
+	s := New()
+	if err := s.AddCategory("P", []string{"p", "t", "k"}); err != nil {
+		t.Fatalf("error when adding category")
+	}
+	tests := []struct {
+		rule   string
+		field  RuleField
+		offset int
+		reason string
+	}{
+		{"ab", FieldRule, 0, "rule must contain the > operator"},
+		{" a( > b", FieldTarget, 1, "missing ) in target"},
+		{"x, a(b > c", FieldTarget, 4, "missing ) in target"},
+		{"a) > b", FieldTarget, 1, "unexpected ) in target"},
+		{"*a > b", FieldTarget, 0, "* must follow an element in target"},
+		{"a[1, 0] > b", FieldTarget, 5, "invalid target index 0"},
+		{"a[99999999999999999999] > b", FieldTarget, 2, "target index 99999999999999999999 is too large"},
+		{"a > b@x", FieldChange, 6, `invalid movement "x" after @ in change`},
+		{"P > b@@1", FieldChange, 6, "too many '@' operators in change"},
+		{"a > b @ 1 @ 2", FieldChange, 10, "too many '@' operators in change"},
+		{"a > b / _c_d", FieldCondition, 10, "invalid condition"},
+		{"ä > b / _c_d", FieldCondition, 10, "invalid condition"},
+		{"a > b / _c, d(e_", FieldCondition, 13, "missing ) in condition"},
+		{"a > b / a _ (b+c", FieldCondition, 12, "missing ) in condition"},
+		{"a > b / _c ! (_", FieldException, 13, "missing ) in condition"},
+		{"a > b / _c ! d_ / x@y", FieldAlternative, 20, `invalid movement "y" after @ in change`},
+		{"a > P", FieldChange, 4, "change to category P needs a single category as its target"},
+		{"ä > b ; rtl, sideways", FieldFlags, 13, `unknown flag "sideways"`},
+		{"a > b ; repeat=0", FieldFlags, 15, `invalid repetition "0"`},
+		{"a > b ; rtl, chance = 2", FieldFlags, 22, `invalid probability "2"`},
+		{"a > b ; rtl=x", FieldFlags, 12, `flag "rtl" does not take a value`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			assert := assert.New(t)
+			_, err := s.NewRule(tt.rule)
+			var pe *ParseError
+			if !assert.True(errors.As(err, &pe)) {
+				return
+			}
+			assert.Equal(pe.Rule, strings.TrimSpace(tt.rule))
+			assert.Equal(pe.Field, tt.field)
+			assert.Equal(pe.Offset, tt.offset)
+			assert.Equal(pe.Reason, tt.reason)
+		})
+	}
+}
+
+func TestParseErrorFromRuleset(t *testing.T) {
+	assert := assert.New(t)
+	s := New()
+	err := s.LoadRuleset(strings.NewReader("a > b\n\na > b / _c_d\n"))
+	var pe *ParseError
+	if !assert.True(errors.As(err, &pe)) {
+		return
+	}
+	assert.Equal(pe.Line, 3)
+	assert.Equal(pe.Field, FieldCondition)
+	assert.Equal(pe.Offset, 10)
+	assert.Equal(pe.Error(), `line 3, column 11: invalid condition in rule "a > b / _c_d": invalid condition`)
+}
@@ -0,0 +1,275 @@
+package scago
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// featureValue is the value of a single distinctive feature, e.g
+// +voice or -cont.
+type featureValue struct {
+	name  string // the name of the feature
+	value bool   // true if the feature is +, false if -
+}
+
+// FeatureMatrix is a list of distinctive feature values, written like
+// [+voice, -cont]. It describes either the features of a sound, or
+// the features that sounds must have to be matched or that a change
+// gives them.
+type FeatureMatrix []featureValue
+
+// String returns the matrix in scago notation, e.g "[+voice, -cont]".
+func (m FeatureMatrix) String() string {
+	values := make([]string, len(m))
+	for i, v := range m {
+		if v.value {
+			values[i] = "+" + v.name
+		} else {
+			values[i] = "-" + v.name
+		}
+	}
+	return "[" + strings.Join(values, ", ") + "]"
+}
+
+// value returns the value of the named feature in m, and false if m
+// doesn't give a value for it.
+func (m FeatureMatrix) value(name string) (bool, bool) {
+	for _, v := range m {
+		if v.name == name {
+			return v.value, true
+		}
+	}
+	return false, false
+}
+
+// matches returns true if the given features have every value in m.
+func (m FeatureMatrix) matches(features FeatureMatrix) bool {
+	for _, v := range m {
+		if value, ok := features.value(v.name); !ok || value != v.value {
+			return false
+		}
+	}
+	return true
+}
+
+// equals returns true if m and the given features have exactly the
+// same values, in any order.
+func (m FeatureMatrix) equals(features FeatureMatrix) bool {
+	return len(m) == len(features) && m.matches(features)
+}
+
+// with returns a copy of m with the values in changes set, replacing
+// any values m already has for the same features.
+func (m FeatureMatrix) with(changes FeatureMatrix) FeatureMatrix {
+	result := make(FeatureMatrix, len(m))
+	copy(result, m)
+	for _, c := range changes {
+		found := false
+		for i := range result {
+			if result[i].name == c.name {
+				result[i].value = c.value
+				found = true
+			}
+		}
+		if !found {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// isFeatureMatrix returns true if the given element is written like a
+// feature matrix, i.e it starts with [+ or [-.
+func isFeatureMatrix(element string) bool {
+	return strings.HasPrefix(element, "[+") || strings.HasPrefix(element, "[-")
+}
+
+// ParseFeatureMatrix returns a FeatureMatrix based on the given input
+// string, e.g "[+voice, -cont]". Each feature name is made up of
+// letters and digits.
+// Returns an error if the matrix could not be parsed.
+func ParseFeatureMatrix(input string) (FeatureMatrix, error) {
+	input = strings.TrimSpace(input)
+	if !strings.HasPrefix(input, "[") || !strings.HasSuffix(input, "]") {
+		return nil, fmt.Errorf("feature matrix %q must be written in brackets", input)
+	}
+	var m FeatureMatrix
+	for _, item := range strings.Split(input[1:len(input)-1], ",") {
+		item = strings.TrimSpace(item)
+		if len(item) < 2 || (item[0] != '+' && item[0] != '-') {
+			return nil, fmt.Errorf("invalid feature value %q, which must be + or - and a feature name", item)
+		}
+		name := item[1:]
+		for _, r := range name {
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+				return nil, fmt.Errorf("invalid feature name %q", name)
+			}
+		}
+		if _, ok := m.value(name); ok {
+			return nil, fmt.Errorf("feature %q given more than once", name)
+		}
+		m = append(m, featureValue{name, item[0] == '+'})
+	}
+	return m, nil
+}
+
+// Sound represents a sound that has been declared with its
+// distinctive features, so that it can be matched by feature matrices
+// in targets and conditions and changed by feature changes.
+// The object forms part of a linked list via the next *Sound,
+// which may be nil in case of being the last in the set.
+type Sound struct {
+	symbol   string        // the sound as written in words
+	features FeatureMatrix // the features of the sound
+	next     *Sound        // the next sound in the linked list
+}
+
+// Symbol returns the sound as written in words.
+func (sd *Sound) Symbol() string {
+	return sd.symbol
+}
+
+// Features returns the features of sd.
+func (sd *Sound) Features() FeatureMatrix {
+	features := make(FeatureMatrix, len(sd.features))
+	copy(features, sd.features)
+	return features
+}
+
+// String returns the declaration of sd as it would be written in a
+// ruleset, e.g "p [-voice, -cont]".
+func (sd *Sound) String() string {
+	return sd.symbol + " " + sd.features.String()
+}
+
+// HasNext returns true if sd is followed by another sound,
+// thus false if this is the last sound in the linked list.
+func (sd *Sound) HasNext() bool {
+	return sd.next != nil
+}
+
+// Append appends a sound to the end of the linked list of sounds.
+func (sd *Sound) Append(sound *Sound) {
+	if sd.HasNext() {
+		sd.next.Append(sound)
+		return
+	}
+	sd.next = sound
+}
+
+// GetSound returns the Sound in s with the given symbol, or nil if no
+// such sound has been declared.
+func (s *Scago) GetSound(symbol string) *Sound {
+	for sd := s.sounds; sd != nil; sd = sd.next {
+		if sd.symbol == symbol {
+			return sd
+		}
+	}
+	return nil
+}
+
+// AddSound declares a sound with the given distinctive features.
+// Sounds must be declared before they are used by a rule.
+// Returns an error if the symbol is blank or already declared.
+func (s *Scago) AddSound(symbol string, features FeatureMatrix) error {
+	symbol = strings.TrimSpace(symbol)
+	if symbol == "" {
+		return errors.New("sound has no symbol")
+	}
+	if s.GetSound(symbol) != nil {
+		return fmt.Errorf("sound %q already declared", symbol)
+	}
+	sound := &Sound{symbol: symbol, features: features}
+	if s.sounds == nil {
+		s.sounds = sound
+	} else {
+		s.sounds.Append(sound)
+	}
+	return nil
+}
+
+// checkFeatures returns an error if any of the features in m isn't
+// given for any sound declared in s.
+func (s *Scago) checkFeatures(m FeatureMatrix) error {
+	for _, v := range m {
+		known := false
+		for sd := s.sounds; sd != nil && !known; sd = sd.next {
+			_, known = sd.features.value(v.name)
+		}
+		if !known {
+			return fmt.Errorf("unknown feature %q", v.name)
+		}
+	}
+	return nil
+}
+
+// featureCategory returns a category of the sounds declared in s that
+// match the feature matrix written in element, to be used like any
+// other category in a target or condition.
+// Returns an error if the matrix could not be parsed, uses an unknown
+// feature, or matches no sounds.
+func (s *Scago) featureCategory(element string) (*Category, error) {
+	m, err := ParseFeatureMatrix(element)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkFeatures(m); err != nil {
+		return nil, err
+	}
+	var sounds []string
+	for sd := s.sounds; sd != nil; sd = sd.next {
+		if m.matches(sd.features) {
+			sounds = append(sounds, sd.symbol)
+		}
+	}
+	if len(sounds) == 0 {
+		return nil, fmt.Errorf("no sounds match %s", m)
+	}
+	return NewCategory(m.String(), sounds)
+}
+
+// featureMapping returns what each sound declared in s becomes when
+// the given feature values are set, which is the declared sound with
+// exactly the resulting features. Sounds that would become a sound
+// that isn't declared are left out.
+// Returns an error if the changes use an unknown feature.
+func (s *Scago) featureMapping(changes FeatureMatrix) (map[string]string, error) {
+	if err := s.checkFeatures(changes); err != nil {
+		return nil, err
+	}
+	mapping := make(map[string]string)
+	for sd := s.sounds; sd != nil; sd = sd.next {
+		result := sd.features.with(changes)
+		for to := s.sounds; to != nil; to = to.next {
+			if to.features.equals(result) {
+				mapping[sd.symbol] = to.symbol
+				break
+			}
+		}
+	}
+	return mapping, nil
+}
+
+// splitElements splits a comma-separated list of targets or
+// conditions, without splitting at commas inside brackets such as
+// those of a feature matrix.
+func splitElements(input string) []string {
+	var elements []string
+	depth, start := 0, 0
+	for i, r := range input {
+		switch r {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case ',':
+			if depth == 0 {
+				elements = append(elements, input[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(elements, input[start:])
+}
@@ -0,0 +1,101 @@
+package scago
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const featureRuleset = `sound: p [-voice, -cont, +lab]
+sound: b [+voice, -cont, +lab]
+sound: t [-voice, -cont, -lab]
+sound: d [+voice, -cont, -lab]
+sound: f [-voice, +cont, +lab]
+sound: s [-voice, +cont, -lab]
+sound: z [+voice, +cont, -lab]
+V = a, e
+`
+
+func newFeatureScago(t *testing.T) *Scago {
+	s := New()
+	if err := s.LoadRuleset(strings.NewReader(featureRuleset)); err != nil {
+		t.Fatalf("error loading ruleset: %s", err)
+	}
+	return s
+}
+
+func TestParseFeatureMatrix(t *testing.T) {
+	assert := assert.New(t)
+	m, err := ParseFeatureMatrix(" [ +voice,-cont ] ")
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Equal(m.String(), "[+voice, -cont]")
+	assert.True(m.matches(FeatureMatrix{{"cont", false}, {"lab", true}, {"voice", true}}))
+	assert.False(m.matches(FeatureMatrix{{"voice", true}}))
+	assert.True(m.equals(FeatureMatrix{{"cont", false}, {"voice", true}}))
+	assert.Equal(m.with(FeatureMatrix{{"cont", true}, {"lab", true}}).String(), "[+voice, +cont, +lab]")
+	for _, invalid := range []string{"+voice", "[]", "[voice]", "[+voice, +voice]", "[+front_round]", "[+]"} {
+		_, err := ParseFeatureMatrix(invalid)
+		assert.Error(err, invalid)
+	}
+}
+
+func TestAddSound(t *testing.T) {
+	assert := assert.New(t)
+	s := newFeatureScago(t)
+	assert.Equal(s.GetSound("b").String(), "b [+voice, -cont, +lab]")
+	assert.Nil(s.GetSound("x"))
+	assert.Error(s.AddSound("b", nil))
+	assert.Error(s.AddSound(" ", nil))
+	assert.Error(s.LoadRuleset(strings.NewReader("sound: x")))
+}
+
+func TestFeatureRules(t *testing.T) {
+	tests := []struct {
+		rule string
+		word string
+		want string
+	}{
+		{"[-voice, -cont] > [+voice] / V_V", "apata", "abada"},
+		{"[-voice] > [+voice] / V_V", "afasa", "afaza"},
+		{"[+voice, -cont] > [+cont, -voice] / _#", "ab", "af"},
+		{"a > e / _[+voice]", "abap", "ebap"},
+		{"a > e / [-cont, +lab]_, _#", "papa", "pape"},
+		{"[+cont, -lab], e > x", "sez", "xxx"},
+		{"[+voice] > [-voice] @1", "ba", "ap"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			assert := assert.New(t)
+			s := newFeatureScago(t)
+			r, err := s.NewRule(tt.rule)
+			if !assert.NoError(err) {
+				return
+			}
+			got, err := r.Apply(tt.word)
+			assert.NoError(err)
+			assert.Equal(got, tt.want)
+		})
+	}
+	t.Run("invalid feature rules", func(t *testing.T) {
+		assert := assert.New(t)
+		s := newFeatureScago(t)
+		assert.Error(s.AddRule("[+nasal] > a"))
+		assert.Error(s.AddRule("a > [+nasal]"))
+		assert.Error(s.AddRule("a > e / _[+voice, +nasal]"))
+		assert.Error(s.AddRule("[+voice, +cont, +lab] > a"))
+	})
+}
+
+func TestExportFeatures(t *testing.T) {
+	assert := assert.New(t)
+	s := newFeatureScago(t)
+	assert.NoError(s.AddRule("[-voice, -cont] > [+voice] / V_V"))
+	sb := &strings.Builder{}
+	if !assert.NoError(s.Export(sb)) {
+		return
+	}
+	assert.Equal(sb.String(), featureRuleset+"[-voice, -cont] > [+voice] / V_V\n")
+}
@@ -0,0 +1,62 @@
+package scago
+
+import (
+	"errors"
+	"slices"
+	"strings"
+)
+
+// SplitTags splits a word as written in a lexicon into the word itself
+// and the tags it is marked with, which are written after the word as
+// a comma-separated list in square brackets, e.g "kamera [loan]".
+// Returns no tags if the word isn't marked with any.
+func SplitTags(entry string) (string, []string) {
+	entry = strings.TrimSpace(entry)
+	if !strings.HasSuffix(entry, "]") {
+		return entry, nil
+	}
+	i := strings.LastIndex(entry, "[")
+	if i < 0 {
+		return entry, nil
+	}
+	return strings.TrimSpace(entry[:i]), splitList(entry[i+1 : len(entry)-1])
+}
+
+// AddExceptions protects the given words from every rule in s, so
+// that they are left as they are by Apply. Words are compared as they
+// are given to Apply, without their tags.
+func (s *Scago) AddExceptions(words []string) error {
+	for _, word := range words {
+		word = strings.TrimSpace(word)
+		if word == "" {
+			return errors.New("blank word given as an exception")
+		}
+		s.exceptions = append(s.exceptions, word)
+	}
+	return nil
+}
+
+// Exceptions returns the words in s that are protected from every
+// rule, in the order they were added.
+func (s *Scago) Exceptions() []string {
+	return slices.Clone(s.exceptions)
+}
+
+// appliesTo returns true if r is to be applied to the given word,
+// marked with the given tags, as it was first given to Apply. This is
+// false if the word is one of the rule's exceptions, if it isn't
+// marked with any of the tags the rule includes, or if it is marked
+// with any of the tags the rule excludes.
+func (r *Rule) appliesTo(word string, tags []string) bool {
+	if slices.Contains(r.exceptions, word) {
+		return false
+	}
+	if len(r.include) > 0 && !slices.ContainsFunc(tags, func(tag string) bool {
+		return slices.Contains(r.include, tag)
+	}) {
+		return false
+	}
+	return !slices.ContainsFunc(tags, func(tag string) bool {
+		return slices.Contains(r.exclude, tag)
+	})
+}
@@ -0,0 +1,100 @@
+package scago
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitTags(t *testing.T) {
+	tests := []struct {
+		entry string
+		word  string
+		tags  []string
+	}{
+		{"kamera", "kamera", nil},
+		{" kamera [loan] ", "kamera", []string{"loan"}},
+		{"kamera [loan, learned]", "kamera", []string{"loan", "learned"}},
+		{"kamera[]", "kamera", nil},
+		{"kamera]", "kamera]", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.entry, func(t *testing.T) {
+			assert := assert.New(t)
+			word, tags := SplitTags(tt.entry)
+			assert.Equal(word, tt.word)
+			assert.Equal(tags, tt.tags)
+		})
+	}
+}
+
+func TestApplyExceptions(t *testing.T) {
+	t.Run("protected words", func(t *testing.T) {
+		assert := assert.New(t)
+		s := New()
+		assert.NoError(s.AddExceptions([]string{"kamera"}))
+		assert.Error(s.AddExceptions([]string{" "}))
+		assert.NoError(s.AddRule("k > h"))
+		assert.NoError(s.AddStage("end"))
+		got, err := s.Apply("kamera")
+		assert.NoError(err)
+		assert.Equal(got, "kamera")
+		got, err = s.Apply("kuma [loan]")
+		assert.NoError(err)
+		assert.Equal(got, "huma")
+		forms, err := s.ApplyStages("kamera")
+		assert.NoError(err)
+		assert.Equal(forms, []string{"kamera"})
+		assert.Equal(s.Exceptions(), []string{"kamera"})
+	})
+	t.Run("rule exceptions", func(t *testing.T) {
+		assert := assert.New(t)
+		s := New()
+		assert.NoError(s.AddRule("k > h ; except=kamera kilo"))
+		assert.NoError(s.AddRule("a > e"))
+		for word, want := range map[string]string{
+			"kamera": "kemere",
+			"kilo":   "kilo",
+			"kuma":   "hume",
+		} {
+			got, err := s.Apply(word)
+			assert.NoError(err)
+			assert.Equal(got, want)
+		}
+	})
+	t.Run("rule tags", func(t *testing.T) {
+		assert := assert.New(t)
+		s := New()
+		assert.NoError(s.AddRule("k > h ; exclude=loan learned"))
+		assert.NoError(s.AddRule("a > e ; include=loan"))
+		for word, want := range map[string]string{
+			"kama":                 "hama",
+			"kama [loan]":          "keme",
+			"kama [learned]":       "kama",
+			"kama [native, loan]":  "keme",
+			"kama [native]":        "hama",
+			"kama [learned, loan]": "keme",
+		} {
+			got, err := s.Apply(word)
+			assert.NoError(err, word)
+			assert.Equal(got, want, word)
+		}
+	})
+	t.Run("invalid flags", func(t *testing.T) {
+		s := New()
+		for _, rule := range []string{"k > h ; except", "k > h ; include=", "k > h ; exclude= "} {
+			_, err := s.NewRule(rule)
+			assert.Error(t, err, rule)
+		}
+	})
+	t.Run("export", func(t *testing.T) {
+		assert := assert.New(t)
+		s := New()
+		ruleset := "except: kamera, radio\nk > h ; except=kilo, include=loan, exclude=learned\n"
+		assert.NoError(s.LoadRuleset(strings.NewReader(ruleset)))
+		sb := &strings.Builder{}
+		assert.NoError(s.Export(sb))
+		assert.Equal(sb.String(), ruleset)
+	})
+}
@@ -2,67 +2,333 @@ package scago
 
 import (
 	"errors"
+	"fmt"
+	"math/rand"
 	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 )
 
+// RepeatLimit is the maximum number of times a rule marked to repeat
+// until the word stops changing will be applied before giving up.
+const RepeatLimit = 100
+
+// RepeatLengthLimit is the maximum number of segments a word may grow
+// to while a rule marked to repeat until the word stops changing is
+// applied to it, since a rule that lengthens the word can otherwise
+// grow it exponentially long well within RepeatLimit.
+const RepeatLengthLimit = 1000
+
+// repeatUntilStable is the repetition of a rule that should be applied
+// until the word stops changing (or RepeatLimit is reached).
+const repeatUntilStable = -1
+
 // Rule represents a sound change rule that can target a sound or set
 // of sounds and imply a change under certain circumstances.
 // The object forms part of a linked list via the next *Rule,
 // which may be nil in case of being the last in the set.
 type Rule struct {
-	target      *Target    // target of the sound change
-	change      *Change    // what's being changed to
-	condition   *Condition // condition for change to take place
-	exception   *Condition // exception to condition
-	alternative *Change    // alternative change in case of exception
-	repetition  int        // times to repeat change
-	next        *Rule      // the next rule in the linked list
-}
-
-// Apply applies this rule to the given word and returns the
-// resulting word. In case of an error, this is returned
-// alongside an empty result.
+	target       *Target    // target of the sound change
+	change       *Change    // what's being changed to
+	condition    *Condition // condition for change to take place
+	exception    *Condition // exception to condition
+	alternative  *Change    // alternative change in case of exception
+	repetition   int        // times to repeat change, or repeatUntilStable
+	reverse      bool       // true if the rule is applied right to left
+	simultaneous bool       // true if all changes are found before any are made
+	segmenter    *Segmenter // splits words into segments
+	block        *Block     // the block the rule belongs to, if any
+	disabled     bool       // true if the rule is not to be applied
+	chance       float64    // if not 0, the probability of a change at each match
+	wordChance   float64    // if not 0, the probability of the rule changing a word at all
+	random       *rand.Rand // source of the rolls for chance and wordChance
+	exceptions   []string   // words the rule is not applied to
+	include      []string   // if not empty, the rule is only applied to words with one of these tags
+	exclude      []string   // the rule is not applied to words with any of these tags
+	next         *Rule      // the next rule in the linked list
+}
+
+// Apply applies this rule to the given word as many times as the
+// rule's repetition asks for and returns the resulting word. In case
+// of an error, this is returned alongside an empty result. The rule's
+// exceptions and tags are not checked, since they refer to words as
+// they were first given to Scago.Apply.
 func (r *Rule) Apply(lemma string) (string, error) {
-	w, err := NewWord(lemma)
+	return r.apply(lemma, &dice{random: r.random})
+}
+
+// apply is Apply, rolling d for the rule's chance or wordChance.
+func (r *Rule) apply(lemma string, d *dice) (string, error) {
+	w, err := r.segmenter.NewWord(lemma)
 	if err != nil {
 		return "", err
 	}
-	for w.Next() {
-		// Make sure the target matches (or no target) and take note
-		// of target length if so, or skip if not.
-		var t int
-		if r.target != nil {
-			t = w.MatchTarget(r.target.pattern)
-			if t < 0 {
-				continue
+	if err := r.applyWord(w, d); err != nil {
+		return "", err
+	}
+	return w.current(), nil
+}
+
+// applyWord applies this rule to w as many times as the rule's
+// repetition asks for, changing w in place and rolling d for the
+// rule's chance or wordChance.
+func (r *Rule) applyWord(w *Word, d *dice) error {
+	lemma := w.current()
+	if err := r.applyRepeated(w, d); err != nil {
+		return err
+	}
+	// The dice are only rolled for the whole word if the rule would
+	// change it, so that words it can't change don't use up a roll
+	if r.wordChance != 0 && w.current() != lemma && !d.roll(r.wordChance) {
+		return w.load(lemma)
+	}
+	return nil
+}
+
+// applyRepeated applies this rule to w as many times as the rule's
+// repetition asks for.
+func (r *Rule) applyRepeated(w *Word, d *dice) error {
+	if r.repetition == repeatUntilStable {
+		for i := 0; i < RepeatLimit; i++ {
+			lemma := w.current()
+			if err := r.applyOnce(w, d); err != nil {
+				return err
+			}
+			if w.current() == lemma {
+				return nil
+			}
+			if len(w.internal)-2 > RepeatLengthLimit {
+				return fmt.Errorf("word did not stop changing before growing past %d segments", RepeatLengthLimit)
 			}
 		}
-		// Check conditions and skip if any do not match
-		if !w.CheckConditions(r.condition, t) {
-			continue
+		return fmt.Errorf("word did not stop changing after %d repetitions", RepeatLimit)
+	}
+	for i := 0; i < r.repetition; i++ {
+		if err := r.applyOnce(w, d); err != nil {
+			return err
 		}
-		// Based on exception/alternative, decide whether to change
-		// to change or exception, or whether we need to skip (exception
-		// with no alternative provided)
-		change := r.change
-		if r.exception != nil {
-			if w.CheckConditions(r.exception, t) {
-				if r.alternative != nil {
-					change = r.alternative
-				}
-			} else {
-				continue
-			}
+	}
+	return nil
+}
+
+// applyOnce applies a single pass of this rule to w, changing it in
+// place.
+func (r *Rule) applyOnce(w *Word, d *dice) error {
+	if err := w.rewind(); err != nil {
+		return err
+	}
+	// If the target is indexed, work out which of its occurrences in
+	// the word (before it is changed) are to be targeted.
+	var selected map[int]bool
+	if r.target != nil && len(r.target.indices) > 0 {
+		selected = r.target.Select(w.Occurrences(r.target.pattern))
+	}
+	next := w.Next
+	if r.reverse {
+		w.Reverse()
+		next = w.Prev
+	}
+	// A rule without a target inserts before each segment it stops
+	// at, so it also has to stop at the end of the word
+	if r.target == nil {
+		next = w.nextGap
+		if r.reverse {
+			w.index++
+			next = w.prevGap
+		}
+	}
+	if r.simultaneous {
+		return r.applySimultaneous(w, next, selected, d)
+	}
+	for next() {
+		change, t := r.match(w, selected)
+		if change == nil || !d.roll(r.chance) {
+			continue
 		}
 		// Time to carry out the change!
 		// change = (*Change) change to carry out
 		// t      = (int) length in word to alter/move/etc
-		err := w.Change(change, t)
-		if err != nil {
-			return "", err
+		if err := w.Change(change, t); err != nil {
+			return err
 		}
 	}
-	return w.String(), nil
+	return nil
+}
+
+// site is a place in a word at which a rule is to make a change.
+type site struct {
+	index  int     // index in w of the start of the target, before any change
+	length int     // length of the target
+	change *Change // change to carry out
+}
+
+// applySimultaneous finds every place in w at which the rule applies
+// before changing anything, and then carries out all of the changes
+// at once, so that no change can feed or bleed another within the
+// same pass. Matches are found in the order given by next, and any
+// match overlapping one found before it is ignored.
+func (r *Rule) applySimultaneous(w *Word, next func() bool, selected map[int]bool, d *dice) error {
+	var sites []site
+	for next() {
+		change, t := r.match(w, selected)
+		if change == nil {
+			continue
+		}
+		if len(sites) > 0 {
+			last := sites[len(sites)-1]
+			if w.index < last.index+last.length && last.index < w.index+max(t, 1) {
+				continue
+			}
+		}
+		if !d.roll(r.chance) {
+			continue
+		}
+		sites = append(sites, site{w.index, t, change})
+	}
+	// Carry out the changes from the end of the word backwards so that
+	// each change leaves the sites before it where they were. Since
+	// nothing had been changed when the sites were found, each site's
+	// index is also where its segment came from in the word.
+	sort.Slice(sites, func(i, j int) bool {
+		return sites[i].index > sites[j].index
+	})
+	for _, site := range sites {
+		if !w.Seek(site.index) {
+			continue
+		}
+		if err := w.Change(site.change, site.length); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dice rolls the dice for rules that apply by chance.
+type dice struct {
+	random  *rand.Rand // source of the rolls, or nil to use the global source
+	certain bool       // true if every roll fires, as though nothing applied by chance
+	record  bool       // true if the outcome of each roll is to be kept
+	rolls   []bool     // if record is true, whether each roll fired, in order
+}
+
+// roll returns true with probability p, or always if p is 0 or d is
+// certain.
+func (d *dice) roll(p float64) bool {
+	if p == 0 || d.certain {
+		return true
+	}
+	var n float64
+	if d.random != nil {
+		n = d.random.Float64()
+	} else {
+		n = rand.Float64()
+	}
+	fired := n < p
+	if d.record {
+		d.rolls = append(d.rolls, fired)
+	}
+	return fired
+}
+
+// match checks whether the rule applies at w's current index,
+// returning the change to carry out there and the length of the
+// target if so, or a nil change if not.
+func (r *Rule) match(w *Word, selected map[int]bool) (*Change, int) {
+	// Make sure the target matches (or no target) and take note
+	// of target length if so, or skip if not.
+	var t int
+	if r.target != nil {
+		t = w.matchTarget(r.target.pattern, r.target.first)
+		if t < 0 {
+			return nil, 0
+		}
+		if selected != nil && !selected[w.Origin()] {
+			return nil, 0
+		}
+	}
+	// Check conditions and skip if any do not match
+	if !w.CheckConditions(r.condition, t) {
+		return nil, 0
+	}
+	// Based on exception/alternative, decide whether to change
+	// to change or alternative, or whether we need to skip (exception
+	// with no alternative provided)
+	if r.exception != nil && w.CheckConditions(r.exception, t) {
+		return r.alternative, t
+	}
+	return r.change, t
+}
+
+// String returns the rule in canonical scago notation, which parses
+// back to an equivalent rule.
+func (r *Rule) String() string {
+	sb := &strings.Builder{}
+	if r.target != nil {
+		sb.WriteString(r.target.String())
+		sb.WriteString(" ")
+	}
+	sb.WriteString(">")
+	if change := r.change.String(); change != "" {
+		sb.WriteString(" ")
+		sb.WriteString(change)
+	}
+	if r.condition != nil || r.exception != nil {
+		sb.WriteString(" /")
+	}
+	if r.condition != nil {
+		sb.WriteString(" ")
+		sb.WriteString(r.condition.String())
+	}
+	if r.exception != nil {
+		sb.WriteString(" ! ")
+		sb.WriteString(r.exception.String())
+		if r.alternative != nil {
+			sb.WriteString(" / ")
+			sb.WriteString(r.alternative.String())
+		}
+	}
+	if flags := r.flags(); len(flags) > 0 {
+		sb.WriteString(" ; ")
+		sb.WriteString(strings.Join(flags, ", "))
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// flags returns the flags that need to be written after r for it to
+// be parsed back with the same options.
+func (r *Rule) flags() []string {
+	var flags []string
+	if r.repetition == repeatUntilStable {
+		flags = append(flags, "repeat")
+	} else if r.repetition != 1 {
+		flags = append(flags, "repeat="+strconv.Itoa(r.repetition))
+	}
+	if r.reverse {
+		flags = append(flags, "rtl")
+	}
+	if r.simultaneous {
+		flags = append(flags, "simultaneous")
+	}
+	if r.chance != 0 {
+		flags = append(flags, "chance="+strconv.FormatFloat(r.chance, 'g', -1, 64))
+	}
+	if r.wordChance != 0 {
+		flags = append(flags, "wordchance="+strconv.FormatFloat(r.wordChance, 'g', -1, 64))
+	}
+	if len(r.exceptions) > 0 {
+		flags = append(flags, "except="+strings.Join(r.exceptions, " "))
+	}
+	if len(r.include) > 0 {
+		flags = append(flags, "include="+strings.Join(r.include, " "))
+	}
+	if len(r.exclude) > 0 {
+		flags = append(flags, "exclude="+strings.Join(r.exclude, " "))
+	}
+	if r.disabled {
+		flags = append(flags, "off")
+	}
+	return flags
 }
 
 // HasNext returns true if the given rule is followed by another
@@ -81,12 +347,13 @@ func (r *Rule) Append(rule *Rule) {
 }
 
 // AddRule creates a new rule according to the given string and
-// adds it to the rules list in s.
+// adds it to the rules list in s, in the current block if any.
 func (s *Scago) AddRule(rule string) error {
 	r, err := s.NewRule(rule)
 	if err != nil {
 		return err
 	}
+	r.block = s.block
 	if s.rules == nil {
 		s.rules = r
 	} else {
@@ -95,42 +362,204 @@ func (s *Scago) AddRule(rule string) error {
 	return nil
 }
 
+// rulePattern splits a rule into its target, change, condition,
+// exception and alternative.
+var rulePattern = regexp.MustCompile(`^(.*?)>(.*?)(?:/(.*?)(?:!(.*?)(?:/(.*?))?)?)?$`)
+
 // NewRule returns a new Rule object according to the given rule string.
-// If the rule could not be parsed, it instead returns nil and an error.
+// A rule may be followed by a semicolon and a comma-separated list of
+// flags, e.g "a > e / _i ; repeat=2".
+// If the rule could not be parsed, it instead returns nil and a
+// *ParseError saying where in the rule the problem is.
 func (s *Scago) NewRule(rule string) (*Rule, error) {
-	re := regexp.MustCompile(`^(.*?)>(.*?)(?:/(.*?)(?:!(.*?)(?:/(.*?))?)?)?$`)
-	parts := re.FindStringSubmatch(rule)
+	text := strings.TrimSpace(rule)
+	rule, flags, hasFlags := strings.Cut(text, ";")
+	// The start and end of each part of the rule, or -1 if not given
+	parts := rulePattern.FindStringSubmatchIndex(rule)
 	if parts == nil {
-		return nil, errors.New("rule does not parse")
+		return nil, newParseError(text, FieldRule, 0, errors.New("rule must contain the > operator"))
+	}
+	part := func(i int) string {
+		if parts[2*i] < 0 {
+			return ""
+		}
+		return rule[parts[2*i]:parts[2*i+1]]
+	}
+	fail := func(field RuleField, i int, err error) error {
+		return newParseError(text, field, parts[2*i], err)
 	}
 
-	target, err := s.ParseTarget(parts[1])
+	target, err := s.ParseTarget(part(1))
 	if err != nil {
-		return nil, err
+		return nil, fail(FieldTarget, 1, err)
 	}
-	change, err := s.ParseChange(parts[2])
+	change, err := s.ParseChange(part(2))
 	if err != nil {
-		return nil, err
+		return nil, fail(FieldChange, 2, err)
 	}
-	condition, err := s.ParseCondition(parts[3])
+	condition, err := s.ParseCondition(part(3))
 	if err != nil {
-		return nil, err
+		return nil, fail(FieldCondition, 3, err)
 	}
-	exception, err := s.ParseCondition(parts[4])
+	exception, err := s.ParseCondition(part(4))
 	if err != nil {
-		return nil, err
+		return nil, fail(FieldException, 4, err)
 	}
-	alternative, err := s.ParseChange(parts[5])
-	if err != nil {
-		return nil, err
+	// Only parse an alternative if one was given, since a blank change
+	// would otherwise be taken to mean deletion
+	var alternative *Change
+	if parts[10] >= 0 {
+		alternative, err = s.ParseChange(part(5))
+		if err != nil {
+			return nil, fail(FieldAlternative, 5, err)
+		}
 	}
 
-	return &Rule{
-		target,
-		change,
-		condition,
-		exception,
-		alternative,
-		1, nil, // TODO: parse for these values too
-	}, nil
+	if err := change.linkTarget(target); err != nil {
+		return nil, fail(FieldChange, 2, err)
+	}
+	if err := alternative.linkTarget(target); err != nil {
+		return nil, fail(FieldAlternative, 5, err)
+	}
+
+	r := &Rule{
+		target:       target,
+		change:       change,
+		condition:    condition,
+		exception:    exception,
+		alternative:  alternative,
+		repetition:   1,
+		simultaneous: s.simultaneous,
+		segmenter:    s.segmenter,
+		random:       s.random,
+	}
+	if hasFlags {
+		if err := r.parseFlags(flags); err != nil {
+			return nil, newParseError(text, FieldFlags, len(rule)+1, err)
+		}
+	}
+	return r, nil
+}
+
+// parseFlags sets the options of r from the given comma-separated list
+// of flags, as written after the semicolon in a rule. Each flag is
+// either a name or a name=value pair. The flags are:
+//
+//	repeat=N      apply the rule N times in a row
+//	repeat        apply the rule until the word stops changing
+//	ltr           scan the word from left to right (the default)
+//	rtl           scan the word from right to left
+//	simultaneous  find every match in the word before changing any
+//	sequential    change each match as soon as it is found (the default)
+//	chance=P      make each change with probability P
+//	wordchance=P  change a word at all with probability P
+//	except=W      don't apply the rule to the space-separated words W
+//	include=T     only apply the rule to words with one of the tags T
+//	exclude=T     don't apply the rule to words with any of the tags T
+//	off           don't apply the rule
+//
+// A rule that is repeated until the word stops changing stops after a
+// pass that changes nothing, even if that is only because every roll
+// of its dice failed.
+//
+// Returns an error if a flag is unknown or its value is invalid, found
+// at the flag or at its value in the input.
+func (r *Rule) parseFlags(input string) error {
+	offset := 0
+	for _, flag := range strings.Split(input, ",") {
+		start := offset
+		offset += countNonSpace(flag) + 1
+		flag = strings.TrimSpace(flag)
+		if flag == "" {
+			continue
+		}
+		if err := r.parseFlag(flag); err != nil {
+			return errorAt(start, err)
+		}
+	}
+	return nil
+}
+
+// parseFlag sets the option of r given by a single flag.
+// Returns an error if the flag is unknown, or if its value is invalid,
+// found at the value.
+func (r *Rule) parseFlag(flag string) error {
+	name, value, hasValue := strings.Cut(flag, "=")
+	name = strings.TrimSpace(name)
+	value = strings.TrimSpace(value)
+	// The value, if any, follows the name and the =
+	atValue := func(err error) error {
+		return errorAt(countNonSpace(name)+1, err)
+	}
+	switch name {
+	case "repeat":
+		if !hasValue || value == "*" {
+			r.repetition = repeatUntilStable
+			return nil
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return atValue(fmt.Errorf("invalid repetition %q", value))
+		}
+		r.repetition = n
+	case "ltr", "rtl":
+		if hasValue {
+			return atValue(fmt.Errorf("flag %q does not take a value", name))
+		}
+		r.reverse = name == "rtl"
+	case "simultaneous", "sequential":
+		if hasValue {
+			return atValue(fmt.Errorf("flag %q does not take a value", name))
+		}
+		r.simultaneous = name == "simultaneous"
+	case "off":
+		if hasValue {
+			return atValue(fmt.Errorf("flag %q does not take a value", name))
+		}
+		r.disabled = true
+	case "chance", "wordchance":
+		p, err := parseProbability(value)
+		if err != nil {
+			return atValue(fmt.Errorf("invalid probability %q", value))
+		}
+		if name == "chance" {
+			r.chance = p
+		} else {
+			r.wordChance = p
+		}
+	case "except", "include", "exclude":
+		list := strings.Fields(value)
+		if len(list) == 0 {
+			return fmt.Errorf("flag %q needs a list of words or tags", name)
+		}
+		switch name {
+		case "except":
+			r.exceptions = append(r.exceptions, list...)
+		case "include":
+			r.include = append(r.include, list...)
+		case "exclude":
+			r.exclude = append(r.exclude, list...)
+		}
+	default:
+		return fmt.Errorf("unknown flag %q", name)
+	}
+	return nil
+}
+
+// parseProbability parses a probability as written in a chance flag,
+// either as a number such as 0.3 or as a percentage such as 30%.
+// Returns an error if the probability is not above 0 and at most 1.
+func parseProbability(value string) (float64, error) {
+	percent := strings.HasSuffix(value, "%")
+	p, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+	if err != nil {
+		return 0, err
+	}
+	if percent {
+		p /= 100
+	}
+	if !(p > 0 && p <= 1) {
+		return 0, errors.New("probability out of range")
+	}
+	return p, nil
 }
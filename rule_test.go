@@ -0,0 +1,311 @@
+package scago
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRule(t *testing.T) {
+	s := New()
+	t.Run("rule without flags", func(t *testing.T) {
+		assert := assert.New(t)
+		got, err := s.NewRule("a > b / _b")
+		if !assert.NoError(err) {
+			return
+		}
+		assert.Equal(got.repetition, 1)
+	})
+	t.Run("rule with repetition", func(t *testing.T) {
+		assert := assert.New(t)
+		got, err := s.NewRule("a > b / _b ; repeat=3")
+		if !assert.NoError(err) {
+			return
+		}
+		assert.Equal(got.repetition, 3)
+	})
+	t.Run("rule repeating until stable", func(t *testing.T) {
+		assert := assert.New(t)
+		got, err := s.NewRule("a > b / _b ; repeat")
+		if !assert.NoError(err) {
+			return
+		}
+		assert.Equal(got.repetition, repeatUntilStable)
+	})
+	t.Run("invalid repetition", func(t *testing.T) {
+		_, err := s.NewRule("a > b ; repeat=0")
+		assert.Error(t, err)
+	})
+	t.Run("rule applied right to left", func(t *testing.T) {
+		assert := assert.New(t)
+		got, err := s.NewRule("a > b / _b ; rtl, repeat=2")
+		if !assert.NoError(err) {
+			return
+		}
+		assert.True(got.reverse)
+		assert.Equal(got.repetition, 2)
+	})
+	t.Run("rule applied simultaneously", func(t *testing.T) {
+		assert := assert.New(t)
+		got, err := s.NewRule("a > b / a_ ; simultaneous")
+		if !assert.NoError(err) {
+			return
+		}
+		assert.True(got.simultaneous)
+	})
+	t.Run("rule applied simultaneously by default", func(t *testing.T) {
+		assert := assert.New(t)
+		s := New()
+		s.SetSimultaneous(true)
+		got, err := s.NewRule("a > b / a_")
+		if !assert.NoError(err) {
+			return
+		}
+		assert.True(got.simultaneous)
+		got, err = s.NewRule("a > b / a_ ; sequential")
+		if !assert.NoError(err) {
+			return
+		}
+		assert.False(got.simultaneous)
+	})
+	t.Run("rule mapping categories", func(t *testing.T) {
+		assert := assert.New(t)
+		s := New()
+		assert.NoError(s.AddCategory("P", []string{"p", "t", "k"}))
+		assert.NoError(s.AddCategory("B", []string{"b", "d", "g"}))
+		assert.NoError(s.AddCategory("V", []string{"a", "e"}))
+		_, err := s.NewRule("P > B")
+		assert.NoError(err)
+		_, err = s.NewRule("V > B")
+		assert.Error(err)
+		_, err = s.NewRule("p > B")
+		assert.Error(err)
+		_, err = s.NewRule("P, V > B")
+		assert.Error(err)
+	})
+	t.Run("rule applied by chance", func(t *testing.T) {
+		assert := assert.New(t)
+		got, err := s.NewRule("a > b ; chance=0.25")
+		if !assert.NoError(err) {
+			return
+		}
+		assert.Equal(got.chance, 0.25)
+		got, err = s.NewRule("a > b ; wordchance=30%")
+		if !assert.NoError(err) {
+			return
+		}
+		assert.Equal(got.wordChance, 0.3)
+	})
+	t.Run("invalid probability", func(t *testing.T) {
+		for _, rule := range []string{"a > b ; chance", "a > b ; chance=0", "a > b ; chance=1.5", "a > b ; wordchance=x"} {
+			_, err := s.NewRule(rule)
+			assert.Error(t, err, rule)
+		}
+	})
+	t.Run("direction with value", func(t *testing.T) {
+		_, err := s.NewRule("a > b ; rtl=1")
+		assert.Error(t, err)
+	})
+	t.Run("unknown flag", func(t *testing.T) {
+		_, err := s.NewRule("a > b ; sideways")
+		assert.Error(t, err)
+	})
+}
+
+func TestRuleString(t *testing.T) {
+	s := New()
+	if err := s.AddCategory("P", []string{"p", "t", "k"}); err != nil {
+		t.Fatalf("error when adding category")
+	}
+	if err := s.AddCategory("B", []string{"b", "d", "g"}); err != nil {
+		t.Fatalf("error when adding category")
+	}
+	tests := []struct {
+		rule string
+		want string
+	}{
+		{"a>e", "a > e"},
+		{" a , b > e / # _ ,_ P ", "a, b > e / #_, _P"},
+		{"a[ -1 ] > ", "a[-1] >"},
+		{"a > / _b", "a > / _b"},
+		{"> e / _#", "> e / _#"},
+		{"P>B@2", "P > B @2"},
+		{"a > @-1 / _# ! b_", "a > @-1 / _# ! b_"},
+		{"a > e / ! b_ / o", "a > e / ! b_ / o"},
+		{"a > e / _c ! b_ /", "a > e / _c ! b_ /"},
+		{"a > e ; rtl, repeat=2, simultaneous", "a > e ; repeat=2, rtl, simultaneous"},
+		{"a > e ; repeat, ltr, sequential", "a > e ; repeat"},
+		{"a > e ; off, wordchance=50%, chance=.1", "a > e ; chance=0.1, wordchance=0.5, off"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			assert := assert.New(t)
+			r, err := s.NewRule(tt.rule)
+			if !assert.NoError(err) {
+				return
+			}
+			assert.Equal(r.String(), tt.want)
+			// The canonical form must parse back to itself
+			r, err = s.NewRule(r.String())
+			if !assert.NoError(err) {
+				return
+			}
+			assert.Equal(r.String(), tt.want)
+		})
+	}
+}
+
+func TestRuleApply(t *testing.T) {
+	s := New()
+	if err := s.AddCategory("P", []string{"p", "t", "k"}); err != nil {
+		t.Fatalf("error when adding category")
+	}
+	if err := s.AddCategory("B", []string{"b", "d", "g"}); err != nil {
+		t.Fatalf("error when adding category")
+	}
+	tests := []struct {
+		rule string
+		word string
+		want string
+	}{
+		{"a > b / _b", "aaab", "aabb"},
+		{"a > b / _b ; repeat=2", "aaab", "abbb"},
+		{"a > b / _b ; repeat", "aaab", "bbbb"},
+		{"a > b / _b ; repeat=*", "aaab", "bbbb"},
+		{"a[1] > e", "banana", "benana"},
+		{"a[-1] > e", "banana", "banane"},
+		{"a[2,3] > e", "banana", "banene"},
+		{"a[-1] > e / _n", "banana", "banana"},
+		{"a[-1] > e / n_", "banana", "banane"},
+		{"a[-1] > ee", "banana", "bananee"},
+		{"a[4] > e", "banana", "banana"},
+		{"a > b / _b ; ltr", "aaab", "aabb"},
+		{"a > b / _b ; rtl", "aaab", "bbbb"},
+		{"b > a / a_ ; rtl", "abbb", "aabb"},
+		{"b > a / a_", "abbb", "aaaa"},
+		{"a > / _a ; rtl", "baaac", "bac"},
+		{"a > / _a", "baaac", "bac"},
+		{"a > e / _b ! #_", "abab", "abeb"},
+		{"a > e / _b ! #_ / o", "abab", "obeb"},
+		{"a > b / a_", "aaaa", "abab"},
+		{"a > b / a_ ; simultaneous", "aaaa", "abbb"},
+		{"a > b / a_ ; simultaneous, rtl", "aaaa", "abbb"},
+		{"aa > b ; simultaneous", "aaaaa", "bba"},
+		{"aa > b ; simultaneous, rtl", "aaaaa", "abb"},
+		{"a > @1 ; simultaneous", "xaxa", "xxaa"},
+		{"P > B", "pataka", "badaga"},
+		{"P > B / a_a", "pataka", "padaga"},
+		{"P > B / _a ! #_ / a", "patak", "aadak"},
+		{"!P > x", "pata", "pxtx"},
+		{"[^P] > x / #_", "apa", "xpa"},
+		{"a > e / _[^P]", "apab", "apeb"},
+		{"a > e / [^P]_", "bapa", "bepa"},
+		{"a > e / _(n)#", "pan", "pen"},
+		{"a > e / _(n)#", "pa", "pe"},
+		{"a > e / _(n)#", "pat", "pat"},
+		{"a > e / _…i", "pakti", "pekti"},
+		{"a > e / _*i", "pakti", "pekti"},
+		{"a > e / _…i", "paktu", "paktu"},
+		{"a > e / _…ʃ#", "aːʃ", "eːʃ"},
+		{"a > e / _P+#", "apt", "ept"},
+		{"a > e / _P+#", "apta", "apta"},
+		{"a > e / #(P)P_", "pta", "pte"},
+		{"> e / _#", "pat", "pate"},
+		{"> e / _# ; rtl", "pat", "pate"},
+		{"> e / _# ; simultaneous", "pat", "pate"},
+		{"> e / _t", "pat", "paet"},
+		{"> e / t_", "pat", "pate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			assert := assert.New(t)
+			r, err := s.NewRule(tt.rule)
+			if !assert.NoError(err) {
+				return
+			}
+			got, err := r.Apply(tt.word)
+			assert.NoError(err)
+			assert.Equal(got, tt.want)
+		})
+	}
+	t.Run("multigraph segments", func(t *testing.T) {
+		assert := assert.New(t)
+		s := New()
+		assert.NoError(s.AddSegments([]string{"th", "aː"}))
+		assert.NoError(s.AddCategory("V", []string{"a", "aː", "i"}))
+		assert.NoError(s.AddRule("t > d"))
+		assert.NoError(s.AddRule("V > @-1 / _#"))
+		assert.NoError(s.AddRule("i[-1] > e"))
+		got, err := s.Apply("thitaː")
+		assert.NoError(err)
+		assert.Equal(got, "theaːd")
+		got, err = s.Apply("thit")
+		assert.NoError(err)
+		assert.Equal(got, "thed")
+	})
+	t.Run("conditions starting partway through a multigraph", func(t *testing.T) {
+		assert := assert.New(t)
+		s := New()
+		assert.NoError(s.AddSegments([]string{"ts"}))
+		assert.NoError(s.AddCategory("S", []string{"sh", "h"}))
+		assert.NoError(s.AddRule("x > y / S_"))
+		got, err := s.Apply("tshx")
+		assert.NoError(err)
+		assert.Equal(got, "tshy")
+		got, err = s.Apply("ahx")
+		assert.NoError(err)
+		assert.Equal(got, "ahy")
+	})
+	t.Run("shorter alternatives ending on a segment boundary", func(t *testing.T) {
+		assert := assert.New(t)
+		s := New()
+		assert.NoError(s.AddSegments([]string{"sh"}))
+		assert.NoError(s.AddCategory("S", []string{"a", "as"}))
+		assert.NoError(s.AddRule("x > y / _S"))
+		got, err := s.Apply("xash")
+		assert.NoError(err)
+		assert.Equal(got, "yash")
+		r, err := s.NewRule("a, as > o")
+		if !assert.NoError(err) {
+			return
+		}
+		got, err = r.Apply("ash")
+		assert.NoError(err)
+		assert.Equal(got, "osh")
+	})
+	t.Run("negated categories with multigraphs", func(t *testing.T) {
+		assert := assert.New(t)
+		s := New()
+		assert.NoError(s.AddSegments([]string{"th", "aː"}))
+		assert.NoError(s.AddCategory("V", []string{"a", "aː"}))
+		assert.NoError(s.AddCategory("T", []string{"t"}))
+		assert.NoError(s.AddRule("V > o / _[^V]"))
+		assert.NoError(s.AddRule("!T > s / _#"))
+		got, err := s.Apply("aaːta")
+		assert.NoError(err)
+		assert.Equal(got, "aots")
+		got, err = s.Apply("tath")
+		assert.NoError(err)
+		assert.Equal(got, "tos")
+	})
+	t.Run("repetition limit", func(t *testing.T) {
+		assert := assert.New(t)
+		r, err := s.NewRule("a > ab / #_ ; repeat")
+		if !assert.NoError(err) {
+			return
+		}
+		_, err = r.Apply("a")
+		assert.Error(err)
+	})
+	t.Run("repetition length limit", func(t *testing.T) {
+		assert := assert.New(t)
+		s := New()
+		assert.NoError(s.AddCategory("V", []string{"a", "e"}))
+		r, err := s.NewRule("V > ee ; repeat")
+		if !assert.NoError(err) {
+			return
+		}
+		_, err = r.Apply("a")
+		assert.EqualError(err, "word did not stop changing before growing past 1000 segments")
+	})
+}
@@ -0,0 +1,219 @@
+package scago
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// LoadRuleset reads a ruleset in the scago plain-text format from r
+// and adds its categories and rules to s, in the order in which they
+// appear. Each line of a ruleset is one of the following:
+//
+//	// a comment, which is ignored (as is anything following // on a line)
+//	segments: th,ts,aː (multigraphs to treat as single segments)
+//	sound: b [+voice, -cont] (a sound and its distinctive features)
+//	block: Old English (the start of a named block of rules)
+//	stage: Old English (a named stage, at which ApplyStages gives the word's form)
+//	syllables: (C)V(C) (the template that words are split into syllables by)
+//	stress: penultimate (the syllable stressed in words without stress marks)
+//	except: kamera, radio (words that no rule is applied to)
+//	test: kapa -> gaba (a word and the form the ruleset should change it into)
+//	example: kapa -> kaba (a word and the form the rule before it should change it into)
+//	P = p,b,t,d,k,g    (a category definition)
+//	a > e / _P         (a sound change rule)
+//
+// Blank lines are ignored. Categories must be defined before they are
+// used by a rule. Returns an error giving the offending line number
+// if any line could not be parsed. If the line is a rule that could
+// not be parsed, the error is a *ParseError with its Line set.
+func (s *Scago) LoadRuleset(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		last := s.lastTest()
+		if err := s.parseRulesetLine(scanner.Text()); err != nil {
+			var pe *ParseError
+			if errors.As(err, &pe) {
+				pe.Line = n
+				return pe
+			}
+			return fmt.Errorf("line %d: %w", n, err)
+		}
+		// Tests remember where they were written, so that failures
+		// can be found in the ruleset
+		if t := s.lastTest(); t != last {
+			t.line = n
+		}
+	}
+	return scanner.Err()
+}
+
+// Export writes the segments, sounds, categories, syllable template,
+// stress, exceptions, rules, blocks, stages and tests of s to w in the
+// scago ruleset format, in canonical notation. Loading the result with
+// LoadRuleset gives a Scago that applies the same changes as s. Since a
+// ruleset can't disable a whole block, each rule of a disabled block is
+// written with the off flag instead.
+func (s *Scago) Export(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if s.segmenter != nil && len(s.segmenter.multigraphs) > 0 {
+		fmt.Fprintf(bw, "segments: %s\n", strings.Join(s.segmenter.multigraphs, ", "))
+	}
+	for sd := s.sounds; sd != nil; sd = sd.next {
+		fmt.Fprintf(bw, "sound: %s\n", sd)
+	}
+	for c := s.categories; c != nil; c = c.next {
+		fmt.Fprintln(bw, c)
+	}
+	if s.segmenter != nil && s.segmenter.template != nil {
+		fmt.Fprintf(bw, "syllables: %s\n", s.segmenter.template)
+		if s.segmenter.stress != 0 {
+			fmt.Fprintf(bw, "stress: %s\n", stressName(s.segmenter.stress))
+		}
+	}
+	if len(s.exceptions) > 0 {
+		fmt.Fprintf(bw, "except: %s\n", strings.Join(s.exceptions, ", "))
+	}
+	// Blocks are written as their first rule is reached, along with any
+	// empty blocks before them
+	b := s.blocks
+	st := s.stages
+	for ; st != nil && st.rule == nil; st = st.next {
+		fmt.Fprintf(bw, "stage: %s\n", st.name)
+	}
+	for r := s.rules; r != nil; r = r.next {
+		for r.block != nil && b != nil && b != r.block.next {
+			fmt.Fprintf(bw, "block: %s\n", b.name)
+			b = b.next
+		}
+		if r.block != nil && r.block.disabled {
+			off := *r
+			off.disabled = true
+			fmt.Fprintln(bw, &off)
+		} else {
+			fmt.Fprintln(bw, r)
+		}
+		for t := s.tests; t != nil; t = t.next {
+			if t.rule == r {
+				fmt.Fprintf(bw, "example: %s\n", t)
+			}
+		}
+		for ; st != nil && st.rule == r; st = st.next {
+			fmt.Fprintf(bw, "stage: %s\n", st.name)
+		}
+	}
+	for ; b != nil; b = b.next {
+		fmt.Fprintf(bw, "block: %s\n", b.name)
+	}
+	for t := s.tests; t != nil; t = t.next {
+		if t.rule == nil {
+			fmt.Fprintf(bw, "test: %s\n", t)
+		}
+	}
+	return bw.Flush()
+}
+
+// parseRulesetLine parses a single line of a ruleset and adds whatever
+// it defines to s.
+func (s *Scago) parseRulesetLine(line string) error {
+	// Strip comments and ignore the line if nothing is left
+	if i := strings.Index(line, "//"); i >= 0 {
+		line = line[:i]
+	}
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return nil
+	}
+	// Directives are a keyword followed by a colon
+	if keyword, value, ok := strings.Cut(line, ":"); ok {
+		switch strings.TrimSpace(keyword) {
+		case "segments":
+			segments := splitList(value)
+			if len(segments) == 0 {
+				return errors.New("no segments given")
+			}
+			return s.AddSegments(segments)
+		case "block":
+			return s.AddBlock(value)
+		case "stage":
+			return s.AddStage(value)
+		case "sound":
+			return s.addSoundDefinition(value)
+		case "syllables":
+			return s.SetSyllableTemplate(value)
+		case "stress":
+			return s.SetStress(value)
+		case "except":
+			words := splitList(value)
+			if len(words) == 0 {
+				return errors.New("no words given")
+			}
+			return s.AddExceptions(words)
+		case "test":
+			input, want, err := parseTest(value)
+			if err != nil {
+				return err
+			}
+			return s.AddTest(input, want)
+		case "example":
+			input, want, err := parseTest(value)
+			if err != nil {
+				return err
+			}
+			return s.AddExample(input, want)
+		}
+	}
+	// Rules always contain the > operator, whereas category
+	// definitions never do
+	if strings.Contains(line, ">") {
+		return s.AddRule(line)
+	}
+	identifier, sounds, ok := strings.Cut(line, "=")
+	if !ok {
+		return errors.New("line is neither a rule nor a category definition")
+	}
+	return s.addCategoryDefinition(identifier, sounds)
+}
+
+// addCategoryDefinition adds a category to s from the identifier and
+// comma-separated list of sounds as written in a ruleset.
+func (s *Scago) addCategoryDefinition(identifier string, sounds string) error {
+	identifier = strings.TrimSpace(identifier)
+	if identifier == "" {
+		return errors.New("category has no identifier")
+	}
+	list := splitList(sounds)
+	if len(list) == 0 {
+		return errors.New("category has no sounds")
+	}
+	return s.AddCategory(identifier, list)
+}
+
+// addSoundDefinition declares a sound in s from its symbol and feature
+// matrix as written in a ruleset, e.g "b [+voice, -cont]".
+func (s *Scago) addSoundDefinition(definition string) error {
+	symbol, features, ok := strings.Cut(definition, "[")
+	if !ok {
+		return errors.New("sound has no features")
+	}
+	m, err := ParseFeatureMatrix("[" + features)
+	if err != nil {
+		return err
+	}
+	return s.AddSound(symbol, m)
+}
+
+// splitList splits a comma-separated list as written in a ruleset,
+// trimming each item and leaving out any that are blank.
+func splitList(input string) []string {
+	var list []string
+	for _, item := range strings.Split(input, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
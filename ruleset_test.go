@@ -0,0 +1,111 @@
+package scago
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadRuleset(t *testing.T) {
+	t.Run("categories, rules, comments and blank lines", func(t *testing.T) {
+		assert := assert.New(t)
+		s := New()
+		err := s.LoadRuleset(strings.NewReader(`
+// plosives
+P = p, b,t,d ,k,g
+
+a > e / _P // front before plosives
+o > u / #_
+`))
+		if !assert.NoError(err) {
+			return
+		}
+		c := s.GetCategory("P")
+		if !assert.NotNil(c) {
+			return
+		}
+		assert.Equal(c.pattern, "(p|b|t|d|k|g)")
+		got, err := s.Apply("obak")
+		assert.NoError(err)
+		assert.Equal(got, "ubek")
+	})
+	t.Run("unparseable line", func(t *testing.T) {
+		assert := assert.New(t)
+		s := New()
+		err := s.LoadRuleset(strings.NewReader("P = p,b\n\nnonsense\n"))
+		if assert.Error(err) {
+			assert.Contains(err.Error(), "line 3")
+		}
+	})
+	t.Run("category with no sounds", func(t *testing.T) {
+		assert := assert.New(t)
+		s := New()
+		err := s.LoadRuleset(strings.NewReader("P = , "))
+		assert.Error(err)
+	})
+}
+
+func TestExport(t *testing.T) {
+	assert := assert.New(t)
+	s := New()
+	err := s.LoadRuleset(strings.NewReader(`
+segments: th, aː
+P = p,t , k
+B = b,d,g
+P > B / aː_a // voicing
+a[-1] > e ; rtl
+`))
+	if !assert.NoError(err) {
+		return
+	}
+	sb := &strings.Builder{}
+	if !assert.NoError(s.Export(sb)) {
+		return
+	}
+	assert.Equal(sb.String(), `segments: aː, th
+P = p, t, k
+B = b, d, g
+P > B / aː_a
+a[-1] > e ; rtl
+`)
+	// Loading the export gives a Scago that does the same
+	exported := New()
+	if !assert.NoError(exported.LoadRuleset(strings.NewReader(sb.String()))) {
+		return
+	}
+	for _, word := range []string{"thaːpa", "kaːta", "patha"} {
+		want, err := s.Apply(word)
+		assert.NoError(err)
+		got, err := exported.Apply(word)
+		assert.NoError(err)
+		assert.Equal(got, want)
+	}
+}
+
+func TestRulesetFiles(t *testing.T) {
+	files, err := filepath.Glob("testdata/*.sca")
+	if !assert.NoError(t, err) {
+		return
+	}
+	for _, file := range files {
+		t.Run(file, func(t *testing.T) {
+			assert := assert.New(t)
+			f, err := os.Open(file)
+			if !assert.NoError(err) {
+				return
+			}
+			defer f.Close()
+			s := New()
+			if !assert.NoError(s.LoadRuleset(f)) {
+				return
+			}
+			assert.NotEmpty(s.Tests())
+			for _, failure := range s.RunTests() {
+				t.Errorf("line %d: %s: got %s (%v)", failure.Test.Line(), failure.Test, failure.Got, failure.Err)
+			}
+		})
+	}
+}
@@ -1,30 +1,134 @@
 package scago
 
+import (
+	"math/rand"
+	"slices"
+	"time"
+)
+
 // Scago is the base object that contains enough information to
 // allow sound changes to be performed on words. It contains
 // rules to be applied and the categories that are used in the
 // rules.
 type Scago struct {
-	rules      *Rule     // a pointer to the first rule in the list
-	categories *Category // a pointer to the first category in the list
+	rules        *Rule      // a pointer to the first rule in the list
+	categories   *Category  // a pointer to the first category in the list
+	simultaneous bool       // true if new rules apply simultaneously by default
+	segmenter    *Segmenter // splits words into segments for the rules
+	blocks       *Block     // a pointer to the first block in the list
+	block        *Block     // the block that new rules are added to
+	stages       *Stage     // a pointer to the first stage in the list
+	sounds       *Sound     // a pointer to the first sound in the list
+	random       *rand.Rand // rolls the dice for rules that apply by chance
+	exceptions   []string   // words that no rule is applied to
+	tests        *Test      // a pointer to the first test in the list
+}
+
+// Step is a single step in the derivation of a word, i.e the
+// result of a rule that changed the word. For a rule that applies by
+// chance, it is also any rule that rolled the dice, whether or not
+// the word changed.
+type Step struct {
+	Rule   *Rule  // the rule that was applied
+	Result string // the word after the rule was applied
+	Rolls  []bool // whether each roll of the dice for the rule fired, in order
 }
 
 // Apply applies the Scago's ruleset to the given word, returning
 // the changed word and any error that came up. If an error is
-// returned, the returned string may be empty.
+// returned, the returned string may be empty. Rules that are not
+// enabled, or are in a disabled block, are skipped.
+// The word may be followed by tags in square brackets as described by
+// SplitTags, which rules can include or exclude. Words that are
+// exceptions to s or to a rule are left as they are by it.
 // TODO: implement this functionally
 func (s *Scago) Apply(lemma string) (string, error) {
-	var err error
+	return s.apply(lemma, &dice{random: s.random}, nil, nil)
+}
+
+// ApplyTrace applies the Scago's ruleset to the given word like
+// Apply, but also returns the derivation of the word: each
+// intermediate form along with the rule that produced it. Rules that
+// did not change the word are left out of the derivation, unless they
+// rolled the dice to decide whether to change it. If an error
+// is returned, the derivation up to the failing rule is still given.
+func (s *Scago) ApplyTrace(lemma string) (string, []Step, error) {
+	var trace []Step
+	result, err := s.apply(lemma, &dice{random: s.random}, &trace, nil)
+	return result, trace, err
+}
+
+// apply applies the Scago's ruleset to the given word, rolling d for
+// rules that apply by chance. It adds a Step to trace for every rule
+// that changes the word if trace is not nil, and adds the form of the
+// word at each stage to stages if stages is not nil.
+func (s *Scago) apply(lemma string, d *dice, trace *[]Step, stages *[]string) (string, error) {
+	lemma, tags := SplitTags(lemma)
+	word := lemma
+	protected := slices.Contains(s.exceptions, word)
+	d.record = trace != nil
+	// The word is split into segments when the first rule is applied,
+	// and then kept as it is changed by each rule after it
+	var w *Word
+	st := s.stages
+	for ; st != nil && st.rule == nil; st = st.next {
+		if stages != nil {
+			*stages = append(*stages, lemma)
+		}
+	}
 	for r := s.rules; r != nil; r = r.next {
-		lemma, err = r.Apply(lemma)
-		if err != nil {
-			return "", err
+		if !protected && r.Enabled() && r.appliesTo(word, tags) {
+			if w == nil {
+				var err error
+				if w, err = s.segmenter.NewWord(lemma); err != nil {
+					return "", err
+				}
+			}
+			d.rolls = nil
+			if err := r.applyWord(w, d); err != nil {
+				return "", err
+			}
+			result := w.current()
+			if trace != nil && (result != lemma || len(d.rolls) > 0) {
+				*trace = append(*trace, Step{r, result, d.rolls})
+			}
+			lemma = result
+		}
+		for ; st != nil && st.rule == r; st = st.next {
+			if stages != nil {
+				*stages = append(*stages, lemma)
+			}
 		}
 	}
 	return lemma, nil
 }
 
-// New returns a new blank instance of Scago.
+// New returns a new blank instance of Scago. Rules that apply by
+// chance roll differently on every run, unless a seed is given with
+// SetSeed.
 func New() *Scago {
-	return &Scago{}
+	return &Scago{
+		segmenter: &Segmenter{},
+		random:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// SetSeed seeds the random numbers used by every rule of s that
+// applies by chance, so that applying the same words in the same
+// order always gives the same results.
+func (s *Scago) SetSeed(seed int64) {
+	if s.random == nil {
+		s.random = rand.New(rand.NewSource(seed))
+		return
+	}
+	s.random.Seed(seed)
+}
+
+// SetSimultaneous sets whether rules added to s from now on are
+// applied simultaneously by default, i.e whether every place a rule
+// applies to is found before any changes are made. This can still be
+// overridden for a single rule with the simultaneous and sequential
+// flags.
+func (s *Scago) SetSimultaneous(simultaneous bool) {
+	s.simultaneous = simultaneous
 }
@@ -0,0 +1,133 @@
+package scago
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyTrace(t *testing.T) {
+	assert := assert.New(t)
+	s := New()
+	assert.NoError(s.AddRule("p > b / a_a"))
+	assert.NoError(s.AddRule("x > y"))
+	assert.NoError(s.AddRule("a > e / #_"))
+	got, trace, err := s.ApplyTrace("apa")
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Equal(got, "eba")
+	if !assert.Len(trace, 2) {
+		return
+	}
+	assert.Equal(trace[0].Rule.String(), "p > b / a_a")
+	assert.Equal(trace[0].Result, "aba")
+	assert.Equal(trace[1].Rule.String(), "a > e / #_")
+	assert.Equal(trace[1].Result, "eba")
+}
+
+func TestApplyChance(t *testing.T) {
+	t.Run("same seed gives same results", func(t *testing.T) {
+		assert := assert.New(t)
+		run := func() []string {
+			s := New()
+			s.SetSeed(42)
+			assert.NoError(s.AddRule("a > e ; chance=0.5"))
+			var results []string
+			for i := 0; i < 10; i++ {
+				result, err := s.Apply("aaaaaaaa")
+				assert.NoError(err)
+				results = append(results, result)
+			}
+			return results
+		}
+		results := run()
+		assert.Equal(results, run())
+		// With eight sites at even odds, some changes are all but
+		// certain to be made and some skipped
+		assert.NotContains(results, "aaaaaaaa")
+		assert.NotContains(results, "eeeeeeee")
+	})
+	t.Run("certain chance always applies", func(t *testing.T) {
+		assert := assert.New(t)
+		s := New()
+		assert.NoError(s.AddRule("a > e ; chance=100%"))
+		assert.NoError(s.AddRule("p > b ; wordchance=1"))
+		got, err := s.Apply("papa")
+		assert.NoError(err)
+		assert.Equal(got, "bebe")
+	})
+	t.Run("word chance changes all or nothing", func(t *testing.T) {
+		assert := assert.New(t)
+		s := New()
+		s.SetSeed(7)
+		assert.NoError(s.AddRule("a > e ; wordchance=0.5"))
+		seen := map[string]bool{}
+		for i := 0; i < 50; i++ {
+			got, err := s.Apply("aaaa")
+			assert.NoError(err)
+			seen[got] = true
+		}
+		assert.Equal(seen, map[string]bool{"aaaa": true, "eeee": true})
+	})
+	t.Run("seed without New", func(t *testing.T) {
+		assert := assert.New(t)
+		s := &Scago{}
+		s.SetSeed(3)
+		assert.NoError(s.AddRule("a > e ; chance=100%"))
+		got, err := s.Apply("pata")
+		assert.NoError(err)
+		assert.Equal(got, "pete")
+	})
+	t.Run("repeat stops when every roll fails", func(t *testing.T) {
+		assert := assert.New(t)
+		s := New()
+		s.SetSeed(5)
+		assert.NoError(s.AddRule("a > e / _e ; repeat, chance=0.5"))
+		seen := map[string]bool{}
+		for i := 0; i < 50; i++ {
+			got, err := s.Apply("aaaaaaae")
+			assert.NoError(err)
+			assert.Regexp("^a*e+$", got)
+			seen[got] = true
+		}
+		// The e only spreads until a pass in which its roll fails, so
+		// it seldom reaches the start of the word
+		assert.Greater(len(seen), 2)
+		assert.Contains(seen, "aaaaaaae")
+	})
+	t.Run("trace records rolls", func(t *testing.T) {
+		assert := assert.New(t)
+		s := New()
+		s.SetSeed(1)
+		assert.NoError(s.AddRule("a > e ; chance=0.5"))
+		assert.NoError(s.AddRule("i > u ; wordchance=0.5"))
+		got, trace, err := s.ApplyTrace("aaaa")
+		if !assert.NoError(err) || !assert.Len(trace, 1) {
+			return
+		}
+		assert.Len(trace[0].Rolls, 4)
+		changed := 0
+		for _, fired := range trace[0].Rolls {
+			if fired {
+				changed++
+			}
+		}
+		assert.Equal(strings.Count(got, "e"), changed)
+		assert.Equal(trace[0].Result, got)
+	})
+}
+
+func TestApplySegmentsChangedWord(t *testing.T) {
+	assert := assert.New(t)
+	s := New()
+	assert.NoError(s.AddSegments([]string{"th"}))
+	assert.NoError(s.AddRule("a >"))
+	// Once the a is gone, t and h make up a single segment th, so h is
+	// no longer a segment after t
+	assert.NoError(s.AddRule("h > x / t_"))
+	got, err := s.Apply("tah")
+	assert.NoError(err)
+	assert.Equal(got, "th")
+}
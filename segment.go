@@ -0,0 +1,299 @@
+package scago
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Segmenter splits words into segments (usually phonemes), which are
+// the units that targets, conditions and movements work on. Declared
+// multigraphs such as "th" or "aː" are treated as single segments,
+// matching the longest multigraph first. Anything else is split into
+// grapheme clusters, i.e a character along with any combining
+// diacritics that follow it.
+type Segmenter struct {
+	multigraphs []string          // declared multigraphs, longest first
+	template    *SyllableTemplate // the template to split words into syllables by, if any
+	stress      int               // the syllable stressed by default (1 is first, -1 is last), or 0 if none
+}
+
+// AddMultigraphs adds the given multigraphs to sg so that they are
+// treated as single segments.
+// Returns an error if any of the multigraphs is blank.
+func (sg *Segmenter) AddMultigraphs(multigraphs []string) error {
+	for _, m := range multigraphs {
+		if strings.TrimSpace(m) == "" {
+			return errors.New("blank multigraph given")
+		}
+	}
+	sg.multigraphs = append(sg.multigraphs, multigraphs...)
+	sort.SliceStable(sg.multigraphs, func(i, j int) bool {
+		return len(sg.multigraphs[i]) > len(sg.multigraphs[j])
+	})
+	return nil
+}
+
+// Segment splits the given string into segments. A nil Segmenter
+// splits into grapheme clusters only.
+func (sg *Segmenter) Segment(s string) []string {
+	var segments []string
+	for s != "" {
+		n := sg.nextSegment(s)
+		segments = append(segments, s[:n])
+		s = s[n:]
+	}
+	return segments
+}
+
+// NewWord returns a new Word object based on the given word as a
+// string, split into segments by sg. It automatically prepends and
+// appends the # marker. Stress marks (ˈ and ˌ) are not segments, but
+// are kept as the stress of the syllable they come before. If no
+// stress is marked, the syllable stressed by default (if any) is
+// given primary stress.
+func (sg *Segmenter) NewWord(lemma string) (*Word, error) {
+	internal := []string{"#"}
+	stress := []Stress{Unstressed}
+	mark := Unstressed
+	for s := strings.TrimSpace(lemma); s != ""; {
+		if m, size := stressAt(s); size > 0 {
+			mark = m
+			s = s[size:]
+			continue
+		}
+		n := sg.nextSegment(s)
+		internal = append(internal, s[:n])
+		stress = append(stress, mark)
+		mark = Unstressed
+		s = s[n:]
+	}
+	if len(internal) == 1 {
+		return nil, errors.New("empty word given")
+	}
+	internal = append(internal, "#")
+	stress = append(stress, Unstressed)
+	origin := make([]int, len(internal))
+	for i := range origin {
+		origin[i] = i
+	}
+	w := &Word{internal: internal, origin: origin, stress: stress, segmenter: sg}
+	w.join()
+	if sg != nil {
+		w.template = sg.template
+		w.placeStress(sg.stress)
+	}
+	return w, nil
+}
+
+// nextSegment returns the length in bytes of the segment at the start
+// of s, which must not be empty.
+func (sg *Segmenter) nextSegment(s string) int {
+	if sg != nil {
+		for _, m := range sg.multigraphs {
+			if strings.HasPrefix(s, m) {
+				return len(m)
+			}
+		}
+	}
+	return nextGraphemeCluster(s)
+}
+
+// nextGraphemeCluster returns the length in bytes of the grapheme
+// cluster at the start of s, which must not be empty. A cluster is a
+// character followed by any combining marks. A double diacritic (e.g
+// the tie bar in t͡s) also joins the character following it into
+// the cluster.
+func nextGraphemeCluster(s string) int {
+	_, n := utf8.DecodeRuneInString(s)
+	for n < len(s) {
+		r, size := utf8.DecodeRuneInString(s[n:])
+		if !unicode.Is(unicode.M, r) {
+			break
+		}
+		n += size
+		if isDoubleDiacritic(r) && n < len(s) {
+			_, size = utf8.DecodeRuneInString(s[n:])
+			n += size
+		}
+	}
+	return n
+}
+
+// isDoubleDiacritic returns true if r is a combining mark that spans
+// two characters, such as the IPA tie bars.
+func isDoubleDiacritic(r rune) bool {
+	return r >= '\u035c' && r <= '\u0362'
+}
+
+const (
+	// doubleDiacritics are the double diacritics, as used in a
+	// regexp character class.
+	doubleDiacritics = `\x{35c}-\x{362}`
+	// markPattern matches a mark following the first character of a
+	// grapheme cluster, along with the character a double diacritic
+	// joins.
+	markPattern = `(?:[^\P{M}` + doubleDiacritics + `]|[` + doubleDiacritics + `][^#])`
+)
+
+// ExcludingPattern returns a regexp pattern that matches any single
+// segment, as split by sg, other than the given sounds. Declared
+// multigraphs are matched whole, so that e.g excluding "t" still
+// matches "th" if it is a multigraph. A sound that is neither a
+// multigraph nor a single grapheme cluster is never a segment, so it
+// makes no difference to the pattern. The pattern never matches a
+// word boundary (#) or a syllable boundary ($).
+func (sg *Segmenter) ExcludingPattern(sounds []string) string {
+	excluded := make(map[string]bool)
+	for _, sound := range sounds {
+		excluded[sound] = true
+	}
+	var alternatives []string
+	if sg != nil {
+		for _, m := range sg.multigraphs {
+			if !excluded[m] {
+				alternatives = append(alternatives, regexp.QuoteMeta(m))
+			}
+		}
+	}
+	var clusters [][]string
+	for _, sound := range sounds {
+		if sound != "" && nextGraphemeCluster(sound) == len(sound) {
+			clusters = append(clusters, clusterParts(sound))
+		}
+	}
+	alternatives = append(alternatives, excludingClusters(clusters, true))
+	return "(?:" + strings.Join(alternatives, "|") + ")"
+}
+
+// clusterParts splits a grapheme cluster into its first character and
+// each mark following it, keeping a double diacritic together with
+// the character it joins.
+func clusterParts(cluster string) []string {
+	_, n := utf8.DecodeRuneInString(cluster)
+	parts := []string{cluster[:n]}
+	for n < len(cluster) {
+		r, size := utf8.DecodeRuneInString(cluster[n:])
+		if isDoubleDiacritic(r) && n+size < len(cluster) {
+			_, joined := utf8.DecodeRuneInString(cluster[n+size:])
+			size += joined
+		}
+		parts = append(parts, cluster[n:n+size])
+		n += size
+	}
+	return parts
+}
+
+// excludingClusters returns a regexp pattern matching any grapheme
+// cluster except the given ones, each split into parts by
+// clusterParts. If first is false, the pattern instead matches any
+// sequence of marks except the given ones, which is what follows a
+// part that an excluded cluster starts with.
+func excludingClusters(clusters [][]string, first bool) string {
+	rest := make(map[string][][]string)
+	var parts []string
+	ended := false
+	for _, c := range clusters {
+		if len(c) == 0 {
+			ended = true
+			continue
+		}
+		if _, ok := rest[c[0]]; !ok {
+			parts = append(parts, c[0])
+		}
+		rest[c[0]] = append(rest[c[0]], c[1:])
+	}
+	sort.Strings(parts)
+	var alternatives []string
+	// Stopping here is fine unless a cluster ends here too
+	if !first && !ended {
+		alternatives = append(alternatives, "")
+	}
+	// Anything that doesn't start like any excluded cluster
+	alternatives = append(alternatives, otherPartPattern(parts, first)+markPattern+"*")
+	// Anything that starts like an excluded cluster but then differs
+	for _, part := range parts {
+		alternatives = append(alternatives, regexp.QuoteMeta(part)+"(?:"+excludingClusters(rest[part], false)+")")
+	}
+	return strings.Join(alternatives, "|")
+}
+
+// otherPartPattern returns a regexp pattern matching any one part of a
+// grapheme cluster other than the given parts. If first is true, the
+// part is the first character of a cluster, and otherwise a mark.
+func otherPartPattern(parts []string, first bool) string {
+	if first {
+		return "[^#$" + runeClass(parts) + "]"
+	}
+	var singles []string
+	joined := make(map[rune][]string)
+	for _, part := range parts {
+		r, size := utf8.DecodeRuneInString(part)
+		if size == len(part) {
+			singles = append(singles, part)
+		} else {
+			joined[r] = append(joined[r], part[size:])
+		}
+	}
+	alternatives := []string{"[^\\P{M}" + doubleDiacritics + runeClass(singles) + "]"}
+	var free []string
+	for r := rune('\u035c'); r <= '\u0362'; r++ {
+		if chars, ok := joined[r]; ok {
+			alternatives = append(alternatives, "["+runeClass([]string{string(r)})+"][^#"+runeClass(chars)+"]")
+		} else {
+			free = append(free, string(r))
+		}
+	}
+	if len(free) > 0 {
+		alternatives = append(alternatives, "["+runeClass(free)+"][^#]")
+	}
+	return "(?:" + strings.Join(alternatives, "|") + ")"
+}
+
+// runeClass returns the characters in the given strings escaped for
+// use inside a regexp character class.
+func runeClass(chars []string) string {
+	sb := &strings.Builder{}
+	for _, c := range chars {
+		for _, r := range c {
+			fmt.Fprintf(sb, `\x{%x}`, r)
+		}
+	}
+	return sb.String()
+}
+
+// segmentCount returns how many of the given segments make up the
+// first length bytes of their concatenation. If length falls in the
+// middle of a segment, returns -1.
+func segmentCount(segments []string, length int) int {
+	n := 0
+	for length > 0 && n < len(segments) {
+		length -= len(segments[n])
+		n++
+	}
+	if length != 0 {
+		return -1
+	}
+	return n
+}
+
+// AddSegments declares multigraphs, such as "th" or "aː", that should
+// be treated as single segments in the words s is applied to.
+// Returns an error if an error was encountered.
+func (s *Scago) AddSegments(segments []string) error {
+	if s.segmenter == nil {
+		s.segmenter = &Segmenter{}
+	}
+	return s.segmenter.AddMultigraphs(segments)
+}
+
+// NewWord returns a new Word object based on the given word as a
+// string, split into segments according to the multigraphs declared
+// in s.
+func (s *Scago) NewWord(lemma string) (*Word, error) {
+	return s.segmenter.NewWord(lemma)
+}
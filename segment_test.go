@@ -0,0 +1,100 @@
+package scago
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSegment(t *testing.T) {
+	t.Run("grapheme clusters", func(t *testing.T) {
+		assert := assert.New(t)
+		var sg *Segmenter
+		assert.Equal(sg.Segment("pat"), []string{"p", "a", "t"})
+		assert.Equal(sg.Segment("ŋa̰ːt"), []string{"ŋ", "a̰", "ː", "t"})
+		assert.Equal(sg.Segment("t͡sa"), []string{"t͡s", "a"})
+	})
+	t.Run("multigraphs", func(t *testing.T) {
+		assert := assert.New(t)
+		sg := &Segmenter{}
+		if !assert.NoError(sg.AddMultigraphs([]string{"th", "aː", "tsh"})) {
+			return
+		}
+		assert.Equal(sg.multigraphs, []string{"aː", "tsh", "th"})
+		assert.Equal(sg.Segment("thaːtshat"), []string{"th", "aː", "tsh", "a", "t"})
+		assert.Equal(sg.Segment("a̰ːts"), []string{"a̰", "ː", "t", "s"})
+	})
+	t.Run("blank multigraph", func(t *testing.T) {
+		assert.Error(t, (&Segmenter{}).AddMultigraphs([]string{"th", " "}))
+	})
+}
+
+func TestSegmentCount(t *testing.T) {
+	assert := assert.New(t)
+	segments := []string{"th", "aː", "t"}
+	assert.Equal(segmentCount(segments, 0), 0)
+	assert.Equal(segmentCount(segments, 2), 1)
+	assert.Equal(segmentCount(segments, 1), -1)
+	assert.Equal(segmentCount(segments, 6), 3)
+	assert.Equal(segmentCount(segments, 5), 2)
+	assert.Equal(segmentCount(segments, 4), -1)
+}
+
+func TestScagoNewWord(t *testing.T) {
+	assert := assert.New(t)
+	s := New()
+	if !assert.NoError(s.AddSegments([]string{"th", "aː"})) {
+		return
+	}
+	w, err := s.NewWord("thaːt")
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Equal(w.internal, []string{"#", "th", "aː", "t", "#"})
+	assert.True(w.Next())
+	assert.Equal(w.MatchTarget(regexp.MustCompile(`^(t)`)), -1)
+	assert.Equal(w.MatchTarget(regexp.MustCompile(`^(th)`)), 1)
+	assert.Equal(w.MatchTarget(regexp.MustCompile(`^(thaː)`)), 2)
+	assert.True(w.MatchPost(regexp.MustCompile(`^aː`), 1))
+	assert.False(w.MatchPost(regexp.MustCompile(`^a`), 1))
+	assert.True(w.Next())
+	assert.True(w.MatchPre(regexp.MustCompile(`th$`)))
+	assert.False(w.MatchPre(regexp.MustCompile(`h$`)))
+	assert.True(w.MatchGlobal(regexp.MustCompile(`aːt`)))
+	assert.False(w.MatchGlobal(regexp.MustCompile(`ha`)))
+}
+
+func TestExcludingPattern(t *testing.T) {
+	tests := []struct {
+		name        string
+		multigraphs []string
+		sounds      []string
+		matches     []string
+		nonMatches  []string
+	}{
+		{"plain", nil, []string{"a", "e"}, []string{"i", "p", "á", "ã"}, []string{"a", "e", "#"}},
+		{"diacritics", nil, []string{"a", "á"}, []string{"e", "ã", "á̃"}, []string{"a", "á"}},
+		{"double diacritics", nil, []string{"t͡s"}, []string{"t", "t͡ʃ", "d͡z"}, []string{"t͡s"}},
+		{"multigraphs", []string{"th", "aː"}, []string{"t", "aː"}, []string{"th", "a", "p"}, []string{"t", "aː"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			sg := &Segmenter{}
+			if tt.multigraphs != nil {
+				assert.NoError(sg.AddMultigraphs(tt.multigraphs))
+			}
+			re, err := regexp.Compile("^" + sg.ExcludingPattern(tt.sounds) + "$")
+			if !assert.NoError(err) {
+				return
+			}
+			for _, m := range tt.matches {
+				assert.True(re.MatchString(m), m)
+			}
+			for _, m := range tt.nonMatches {
+				assert.False(re.MatchString(m), m)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package scago
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Stage represents a named point between two rules in a ruleset, e.g
+// the point at which Proto-Germanic becomes Old English. The form of a
+// word at each stage can be found with ApplyStages.
+// The object forms part of a linked list via the next *Stage,
+// which may be nil in case of being the last in the set.
+type Stage struct {
+	name string // the name of the stage
+	rule *Rule  // the last rule before the stage, or nil if it comes before every rule
+	next *Stage // the next stage in the linked list
+}
+
+// Name returns the name of st.
+func (st *Stage) Name() string {
+	return st.name
+}
+
+// HasNext returns true if st is followed by another stage,
+// thus false if this is the last stage in the linked list.
+func (st *Stage) HasNext() bool {
+	return st.next != nil
+}
+
+// Append appends a stage to the end of the linked list of stages.
+func (st *Stage) Append(stage *Stage) {
+	if st.HasNext() {
+		st.next.Append(stage)
+		return
+	}
+	st.next = stage
+}
+
+// Stages returns the names of the stages in s, in order.
+func (s *Scago) Stages() []string {
+	var names []string
+	for st := s.stages; st != nil; st = st.next {
+		names = append(names, st.name)
+	}
+	return names
+}
+
+// AddStage adds a stage with the given name after the rules added to
+// s so far. A stage added before any rules gives the form of a word
+// before it is changed. Returns an error if the name is blank or
+// already used.
+func (s *Scago) AddStage(name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errors.New("stage has no name")
+	}
+	for st := s.stages; st != nil; st = st.next {
+		if st.name == name {
+			return fmt.Errorf("stage %q already exists", name)
+		}
+	}
+	st := &Stage{name: name}
+	for r := s.rules; r != nil; r = r.next {
+		st.rule = r
+	}
+	if s.stages == nil {
+		s.stages = st
+	} else {
+		s.stages.Append(st)
+	}
+	return nil
+}
+
+// ApplyStages applies the Scago's ruleset to the given word like
+// Apply, but returns the form of the word at each of the stages in s,
+// in the same order as Stages. If an error is returned, the forms at
+// the stages before the failing rule are still given.
+func (s *Scago) ApplyStages(lemma string) ([]string, error) {
+	var forms []string
+	_, err := s.apply(lemma, &dice{random: s.random}, nil, &forms)
+	return forms, err
+}
@@ -0,0 +1,63 @@
+package scago
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const stageRuleset = `stage: PIE
+p > f
+stage: PGmc
+a > e
+x > y
+stage: OE
+e > i
+stage: ME
+`
+
+func TestAddStage(t *testing.T) {
+	assert := assert.New(t)
+	s := New()
+	if !assert.NoError(s.LoadRuleset(strings.NewReader(stageRuleset))) {
+		return
+	}
+	assert.Equal(s.Stages(), []string{"PIE", "PGmc", "OE", "ME"})
+	assert.Error(s.AddStage("OE"))
+	assert.Error(s.AddStage(""))
+}
+
+func TestApplyStages(t *testing.T) {
+	assert := assert.New(t)
+	s := New()
+	if !assert.NoError(s.LoadRuleset(strings.NewReader(stageRuleset))) {
+		return
+	}
+	forms, err := s.ApplyStages("pata")
+	assert.NoError(err)
+	assert.Equal(forms, []string{"pata", "fata", "fete", "fiti"})
+
+	// Disabled rules are skipped, but their stages are still given
+	s.Rules()[3].SetEnabled(false)
+	forms, err = s.ApplyStages("pata")
+	assert.NoError(err)
+	assert.Equal(forms, []string{"pata", "fata", "fete", "fete"})
+
+	forms, err = New().ApplyStages("pata")
+	assert.NoError(err)
+	assert.Empty(forms)
+}
+
+func TestExportStages(t *testing.T) {
+	assert := assert.New(t)
+	s := New()
+	if !assert.NoError(s.LoadRuleset(strings.NewReader(stageRuleset))) {
+		return
+	}
+	sb := &strings.Builder{}
+	if !assert.NoError(s.Export(sb)) {
+		return
+	}
+	assert.Equal(sb.String(), stageRuleset)
+}
@@ -0,0 +1,202 @@
+package scago
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Stress is the stress of a syllable.
+type Stress int
+
+const (
+	Unstressed Stress = iota // no stress
+	Secondary                // secondary stress, marked with ˌ
+	Primary                  // primary stress, marked with ˈ
+)
+
+// Stress marks as written in words.
+const (
+	PrimaryMark   = "ˈ"
+	SecondaryMark = "ˌ"
+)
+
+// Mark returns the mark written in front of a syllable with stress st,
+// or an empty string if st is Unstressed.
+func (st Stress) Mark() string {
+	switch st {
+	case Primary:
+		return PrimaryMark
+	case Secondary:
+		return SecondaryMark
+	}
+	return ""
+}
+
+// stressAt returns the stress given by the mark at the start of s and
+// the length of the mark in bytes, or Unstressed if s doesn't start
+// with a stress mark.
+func stressAt(s string) (Stress, int) {
+	switch {
+	case strings.HasPrefix(s, PrimaryMark):
+		return Primary, len(PrimaryMark)
+	case strings.HasPrefix(s, SecondaryMark):
+		return Secondary, len(SecondaryMark)
+	}
+	return Unstressed, 0
+}
+
+// stressPositions are the names of the syllables that can be stressed
+// by default, along with which syllable each stands for.
+var stressPositions = []struct {
+	name     string
+	syllable int
+}{
+	{"initial", 1},
+	{"peninitial", 2},
+	{"final", -1},
+	{"penultimate", -2},
+	{"antepenultimate", -3},
+}
+
+// SetStress sets which syllable is given primary stress in words that
+// don't have any stress marked, counting from 1 at the start of the
+// word or from -1 at the end. The syllable can also be given by name:
+// initial, peninitial, final, penultimate or antepenultimate. A word
+// with too few syllables is stressed on the syllable nearest to the
+// one given. A syllable template must have been set.
+// Returns an error if the syllable could not be parsed.
+func (s *Scago) SetStress(syllable string) error {
+	if s.segmenter == nil || s.segmenter.template == nil {
+		return errors.New("stress set without a syllable template")
+	}
+	syllable = strings.TrimSpace(syllable)
+	for _, p := range stressPositions {
+		if syllable == p.name {
+			s.segmenter.stress = p.syllable
+			return nil
+		}
+	}
+	n, err := strconv.Atoi(syllable)
+	if err != nil || n == 0 {
+		return fmt.Errorf("invalid stressed syllable %q", syllable)
+	}
+	s.segmenter.stress = n
+	return nil
+}
+
+// stressName returns the syllable stressed by default in the way it
+// would be written in a ruleset, e.g "penultimate" for -2.
+func stressName(syllable int) string {
+	for _, p := range stressPositions {
+		if syllable == p.syllable {
+			return p.name
+		}
+	}
+	return strconv.Itoa(syllable)
+}
+
+// placeStress moves any stress marked on w onto the nucleus of the
+// syllable it is in, so that it stays with the syllable when the word
+// is split into syllables differently. If no stress is marked, the
+// syllable given by stressed is given primary stress, unless stressed
+// is 0. Nothing is done if w has no syllable template.
+func (w *Word) placeStress(stressed int) {
+	if w.template == nil {
+		return
+	}
+	w.syllabify()
+	marked := false
+	for i, stress := range w.stress {
+		if stress != Unstressed {
+			marked = true
+			w.stress[i] = Unstressed
+			w.stress[w.nucleus(w.syllables[i])] = max(w.stress[w.nucleus(w.syllables[i])], stress)
+		}
+	}
+	if marked || stressed == 0 {
+		return
+	}
+	count := w.syllables[len(w.internal)-2] + 1
+	syllable := stressed - 1
+	if stressed < 0 {
+		syllable = count + stressed
+	}
+	syllable = min(max(syllable, 0), count-1)
+	w.stress[w.nucleus(syllable)] = Primary
+}
+
+// nucleus returns the index of the nucleus of the given syllable of
+// w, or of its first segment if it has no nucleus.
+func (w *Word) nucleus(syllable int) int {
+	first := -1
+	for i, s := range w.syllables {
+		if s != syllable {
+			continue
+		}
+		if w.positions[i] == Nucleus {
+			return i
+		}
+		if first < 0 {
+			first = i
+		}
+	}
+	return first
+}
+
+// SyllableStress returns the stress of the syllable that the segment
+// at w's current index is in.
+func (w *Word) SyllableStress() Stress {
+	return w.syllableStress(w.index)
+}
+
+// syllableStress returns the stress of the syllable that the segment
+// at index i is in, which is the greatest stress marked on any of its
+// segments.
+func (w *Word) syllableStress(i int) Stress {
+	w.syllabify()
+	if i <= 0 || i >= len(w.internal)-1 {
+		return Unstressed
+	}
+	stress := Unstressed
+	for j, s := range w.syllables {
+		if s == w.syllables[i] {
+			stress = max(stress, w.stress[j])
+		}
+	}
+	return stress
+}
+
+// setSyllableStress gives the syllable that the segment at w's current
+// index is in the given stress. Primary stress is moved there, i.e
+// every other syllable loses any primary stress it had.
+func (w *Word) setSyllableStress(stress Stress) {
+	w.syllabify()
+	syllable := w.syllables[w.index]
+	for i, s := range w.syllables {
+		if s == syllable || (stress == Primary && w.stress[i] == Primary) {
+			w.stress[i] = Unstressed
+		}
+	}
+	if w.template == nil {
+		w.stress[w.index] = stress
+		return
+	}
+	w.stress[w.nucleus(syllable)] = stress
+}
+
+// stressMark returns the stress mark to write in front of the segment
+// at index i, if any. With a syllable template, marks are written in
+// front of the first segment of a stressed syllable, and otherwise in
+// front of the segment they were marked on.
+func (w *Word) stressMark(i int) string {
+	if w.template == nil {
+		return w.stress[i].Mark()
+	}
+	w.syllabify()
+	if i > 1 && w.syllables[i] == w.syllables[i-1] {
+		return ""
+	}
+	return w.syllableStress(i).Mark()
+}
@@ -0,0 +1,137 @@
+package scago
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewWordStress(t *testing.T) {
+	tests := []struct {
+		template string
+		stress   string
+		word     string
+		want     string
+	}{
+		{"", "", "paˈta", "paˈta"},
+		{"", "", "ˌpaˈta", "ˌpaˈta"},
+		{"(C)V(C)", "", "paˈta", "paˈta"},
+		{"(C)V(C)", "", "pˈata", "ˈpata"},
+		{"(C)V(C)", "", "pata", "pata"},
+		{"(C)V(C)", "penultimate", "patata", "paˈtata"},
+		{"(C)V(C)", "penultimate", "pa", "ˈpa"},
+		{"(C)V(C)", "initial", "patata", "ˈpatata"},
+		{"(C)V(C)", "3", "patata", "pataˈta"},
+		{"(C)V(C)", "penultimate", "ˈpatata", "ˈpatata"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.template+" "+tt.stress+" "+tt.word, func(t *testing.T) {
+			assert := assert.New(t)
+			s := New()
+			if tt.template != "" {
+				s = newSyllableScago(t, tt.template)
+			}
+			if tt.stress != "" {
+				assert.NoError(s.SetStress(tt.stress))
+			}
+			w, err := s.NewWord(tt.word)
+			if !assert.NoError(err) {
+				return
+			}
+			assert.Equal(w.String(), tt.want)
+			assert.NotContains(w.internal, PrimaryMark)
+		})
+	}
+	t.Run("only stress marks", func(t *testing.T) {
+		_, err := NewWord("ˈ")
+		assert.Error(t, err)
+	})
+}
+
+func TestSetStress(t *testing.T) {
+	assert := assert.New(t)
+	assert.Error(New().SetStress("penultimate"))
+	s := newSyllableScago(t, "(C)V(C)")
+	assert.NoError(s.SetStress(" final "))
+	assert.Equal(s.segmenter.stress, -1)
+	assert.NoError(s.SetStress("-4"))
+	assert.Equal(s.segmenter.stress, -4)
+	assert.Error(s.SetStress("0"))
+	assert.Error(s.SetStress("last"))
+}
+
+func TestStressRules(t *testing.T) {
+	tests := []struct {
+		rule string
+		word string
+		want string
+	}{
+		{"V > e / {unstressed}", "patata", "peˈtate"},
+		{"a > aː / {stressed}, _$", "patata", "paˈtaːta"},
+		{"a > e / {secondary}", "ˌpataˈta", "ˌpetaˈta"},
+		{"a > e / {primary}", "ˌpataˈta", "ˌpataˈte"},
+		{"V > ˈ / {-1}", "patata", "pataˈta"},
+		{"V > ˌ / {1}", "patata", "ˌpaˈtata"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			assert := assert.New(t)
+			s := newSyllableScago(t, "(C)V(C)")
+			assert.NoError(s.SetStress("penultimate"))
+			if !assert.NoError(s.AddRule(tt.rule)) {
+				return
+			}
+			got, err := s.Apply(tt.word)
+			assert.NoError(err)
+			assert.Equal(got, tt.want)
+		})
+	}
+	t.Run("stress marks are not segments", func(t *testing.T) {
+		assert := assert.New(t)
+		s := New()
+		assert.NoError(s.AddRule("at[1] > x"))
+		assert.NoError(s.AddRule("p > @2"))
+		got, err := s.Apply("paˈtata")
+		assert.NoError(err)
+		assert.Equal(got, "ˈxapta")
+	})
+	t.Run("reduction to a vowel outside the template", func(t *testing.T) {
+		assert := assert.New(t)
+		s := newSyllableScago(t, "(C)V(C)")
+		assert.NoError(s.SetStress("penultimate"))
+		assert.NoError(s.AddRule("V > ə / {unstressed}"))
+		// ə isn't in V, so pə can't be a syllable of its own and
+		// joins the stressed syllable after it
+		got, err := s.Apply("patata")
+		assert.NoError(err)
+		assert.Equal(got, "ˈpətatə")
+		// With ə in V, each syllable keeps its own stress
+		s = New()
+		assert.NoError(s.AddCategory("C", []string{"p", "t"}))
+		assert.NoError(s.AddCategory("V", []string{"a", "ə"}))
+		assert.NoError(s.SetSyllableTemplate("(C)V(C)"))
+		assert.NoError(s.SetStress("penultimate"))
+		assert.NoError(s.AddRule("V > ə / {unstressed}"))
+		got, err = s.Apply("patata")
+		assert.NoError(err)
+		assert.Equal(got, "pəˈtatə")
+	})
+	t.Run("stress condition without template", func(t *testing.T) {
+		assert.Error(t, New().AddRule("a > e / {stressed}"))
+	})
+}
+
+func TestExportStress(t *testing.T) {
+	assert := assert.New(t)
+	ruleset := "C = p, t\nV = a, e\nsyllables: (C)V(C)\nstress: penultimate\nV > ˈ / {1}\n"
+	s := New()
+	if !assert.NoError(s.LoadRuleset(strings.NewReader(ruleset))) {
+		return
+	}
+	sb := &strings.Builder{}
+	if !assert.NoError(s.Export(sb)) {
+		return
+	}
+	assert.Equal(sb.String(), ruleset)
+}
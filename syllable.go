@@ -0,0 +1,298 @@
+package scago
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// SyllablePosition is the part of a syllable that a segment is in.
+type SyllablePosition int
+
+const (
+	Onset   SyllablePosition = iota + 1 // the segments before the nucleus
+	Nucleus                             // the segment at the centre of the syllable
+	Coda                                // the segments after the nucleus
+)
+
+// String returns the name of the position as used in conditions,
+// e.g "onset".
+func (p SyllablePosition) String() string {
+	switch p {
+	case Onset:
+		return "onset"
+	case Nucleus:
+		return "nucleus"
+	case Coda:
+		return "coda"
+	}
+	return ""
+}
+
+// templateElement is a single element of a syllable template, which
+// matches one segment.
+type templateElement struct {
+	category *Category // if not nil, the category the segment must (or must not) be in
+	negated  bool      // true if the segment must not be in the category
+	sound    string    // if category is nil, the segment itself
+	optional bool      // true if the element may be left out
+}
+
+// matches returns true if the given segment matches e.
+func (e templateElement) matches(segment string) bool {
+	if e.category != nil {
+		return (e.category.Index(segment) >= 0) != e.negated
+	}
+	return segment == e.sound
+}
+
+// same returns true if e and other match the same segments.
+func (e templateElement) same(other templateElement) bool {
+	return e.category == other.category && e.negated == other.negated && e.sound == other.sound
+}
+
+// SyllableTemplate describes the shape of a syllable, e.g (C)(C)V(C),
+// as a sequence of elements which each match a single segment and
+// which may be optional. One element that isn't optional is the
+// nucleus, those before it make up the onset and those after it
+// make up the coda.
+type SyllableTemplate struct {
+	onset   []templateElement
+	nucleus templateElement
+	coda    []templateElement
+	source  string // the template as written, without spaces
+}
+
+// String returns the template in scago notation.
+func (t *SyllableTemplate) String() string {
+	return t.source
+}
+
+// SetSyllableTemplate sets the template that words are split into
+// syllables by, e.g "(C)(C)V(C)". Each element of the template is a
+// category identifier, a negated category ([^V]) or a single segment,
+// and is optional if written in parentheses. The nucleus may be marked
+// by writing it in angle brackets, e.g "C<V>C", and otherwise is found
+// as described by ParseSyllableTemplate. Categories must be defined
+// before they are used in the template.
+// Returns an error if the template could not be parsed.
+func (s *Scago) SetSyllableTemplate(template string) error {
+	t, err := s.ParseSyllableTemplate(template)
+	if err != nil {
+		return err
+	}
+	if s.segmenter == nil {
+		s.segmenter = &Segmenter{}
+	}
+	s.segmenter.template = t
+	return nil
+}
+
+// ParseSyllableTemplate returns a SyllableTemplate based on the given
+// input string, e.g "(C)(C)V(C)". The nucleus is the element written
+// in angle brackets, if there is one. Otherwise it is the element that
+// isn't optional and that nothing else in the template is the same as,
+// such as V in CV(C), where the consonant slots are all C; the last
+// one if there are several. Returns an error if the template could not
+// be parsed or has no nucleus, i.e no element that isn't optional.
+func (s *Scago) ParseSyllableTemplate(input string) (*SyllableTemplate, error) {
+	source := strings.Join(strings.Fields(input), "")
+	var elements []templateElement
+	marked := -1
+	for input := source; input != ""; {
+		var e templateElement
+		switch {
+		case strings.HasPrefix(input, "("), strings.HasPrefix(input, "<"):
+			closing := ")"
+			if input[0] == '<' {
+				closing = ">"
+			}
+			end := strings.Index(input, closing)
+			if end < 0 {
+				return nil, fmt.Errorf("missing %s in syllable template", closing)
+			}
+			var err error
+			e, err = s.parseTemplateElement(input[1:end])
+			if err != nil {
+				return nil, err
+			}
+			if closing == ")" {
+				e.optional = true
+			} else if marked >= 0 {
+				return nil, errors.New("syllable template has more than one nucleus marked")
+			} else {
+				marked = len(elements)
+			}
+			input = input[end+1:]
+		default:
+			n := s.segmenter.nextSegment(input)
+			if strings.HasPrefix(input, "[") {
+				n = strings.Index(input, "]") + 1
+				if n == 0 {
+					return nil, errors.New("missing ] in syllable template")
+				}
+			} else if _, size := utf8.DecodeRuneInString(input); s.GetCategory(input[:size]) != nil {
+				n = size
+			}
+			var err error
+			e, err = s.parseTemplateElement(input[:n])
+			if err != nil {
+				return nil, err
+			}
+			input = input[n:]
+		}
+		elements = append(elements, e)
+	}
+	nucleus := marked
+	if nucleus < 0 {
+		nucleus = findNucleus(elements)
+	}
+	if nucleus < 0 {
+		return nil, errors.New("syllable template has no nucleus")
+	}
+	return &SyllableTemplate{
+		onset:   elements[:nucleus],
+		nucleus: elements[nucleus],
+		coda:    elements[nucleus+1:],
+		source:  source,
+	}, nil
+}
+
+// findNucleus returns the index of the element that is the nucleus of
+// a syllable template made up of the given elements, none of which is
+// marked as the nucleus: the last element that isn't optional and that
+// no other element is the same as, or failing that the last element
+// that isn't optional. Returns -1 if every element is optional.
+func findNucleus(elements []templateElement) int {
+	last, unique := -1, -1
+	for i, e := range elements {
+		if e.optional {
+			continue
+		}
+		last = i
+		if !slices.ContainsFunc(elements[:i], e.same) && !slices.ContainsFunc(elements[i+1:], e.same) {
+			unique = i
+		}
+	}
+	if unique >= 0 {
+		return unique
+	}
+	return last
+}
+
+// parseTemplateElement parses a single element of a syllable
+// template, which is a category identifier, a negated category or a
+// single segment.
+func (s *Scago) parseTemplateElement(element string) (templateElement, error) {
+	if c := s.GetCategory(element); c != nil {
+		return templateElement{category: c}, nil
+	}
+	if strings.HasPrefix(element, "[^") && strings.HasSuffix(element, "]") {
+		if c := s.GetCategory(element[2 : len(element)-1]); c != nil {
+			return templateElement{category: c, negated: true}, nil
+		}
+	}
+	if element == "" || strings.ContainsAny(element, "()[]") || len(s.segmenter.Segment(element)) != 1 {
+		return templateElement{}, fmt.Errorf("invalid element %q in syllable template", element)
+	}
+	return templateElement{sound: element}, nil
+}
+
+// fits returns true if the given segments can be matched, in order,
+// by the given template elements.
+func fits(elements []templateElement, segments []string) bool {
+	if len(elements) == 0 {
+		return len(segments) == 0
+	}
+	if elements[0].optional && fits(elements[1:], segments) {
+		return true
+	}
+	return len(segments) > 0 && elements[0].matches(segments[0]) && fits(elements[1:], segments[1:])
+}
+
+// Syllabify splits the given segments into syllables, returning the
+// number of the syllable (counting from 0) and the position in it of
+// each segment. Every segment that matches the nucleus of t starts a
+// new syllable, and the segments between two nuclei are split so that
+// the second syllable has the longest onset that t allows, as long as
+// what's left is a coda that t allows. If no split fits t, the onset
+// is still made as long as t allows and anything left over goes to
+// the coda. A word without any nucleus is a single syllable.
+func (t *SyllableTemplate) Syllabify(segments []string) ([]int, []SyllablePosition) {
+	syllables := make([]int, len(segments))
+	positions := make([]SyllablePosition, len(segments))
+	for i := range positions {
+		positions[i] = Onset
+	}
+	var nuclei []int
+	for i, segment := range segments {
+		if t.nucleus.matches(segment) {
+			nuclei = append(nuclei, i)
+		}
+	}
+	for n, nucleus := range nuclei {
+		positions[nucleus] = Nucleus
+		end := len(segments)
+		if n+1 < len(nuclei) {
+			end = nuclei[n+1]
+		}
+		cluster := segments[nucleus+1 : end]
+		// The cluster after the last nucleus is all coda
+		onset := 0
+		if n+1 < len(nuclei) {
+			onset = t.splitCluster(cluster)
+		}
+		for i := nucleus; i < end; i++ {
+			syllables[i] = n
+			if i > nucleus && i < end-onset {
+				positions[i] = Coda
+			}
+		}
+		for i := end - onset; i < end; i++ {
+			syllables[i] = n + 1
+		}
+	}
+	return syllables, positions
+}
+
+// splitCluster returns how many segments at the end of the given
+// cluster between two nuclei belong to the onset of the second
+// syllable.
+func (t *SyllableTemplate) splitCluster(cluster []string) int {
+	for onset := len(cluster); onset >= 0; onset-- {
+		split := len(cluster) - onset
+		if fits(t.onset, cluster[split:]) && fits(t.coda, cluster[:split]) {
+			return onset
+		}
+	}
+	for onset := len(cluster); onset > 0; onset-- {
+		if fits(t.onset, cluster[len(cluster)-onset:]) {
+			return onset
+		}
+	}
+	return 0
+}
+
+// Syllabify splits the given word into segments and syllables
+// according to the syllable template of s, returning the syllables.
+// Returns an error if s has no syllable template or the word is empty.
+func (s *Scago) Syllabify(lemma string) ([]string, error) {
+	w, err := s.NewWord(lemma)
+	if err != nil {
+		return nil, err
+	}
+	if w.template == nil {
+		return nil, errors.New("no syllable template has been set")
+	}
+	w.syllabify()
+	var syllables []string
+	for i := 1; i < len(w.internal)-1; i++ {
+		if i == 1 || w.syllables[i] != w.syllables[i-1] {
+			syllables = append(syllables, "")
+		}
+		syllables[len(syllables)-1] += w.internal[i]
+	}
+	return syllables, nil
+}
@@ -0,0 +1,163 @@
+package scago
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newSyllableScago(t *testing.T, template string) *Scago {
+	s := New()
+	if err := s.AddCategory("C", []string{"p", "t", "k", "b", "d", "g", "s", "n", "r"}); err != nil {
+		t.Fatalf("error when adding category")
+	}
+	if err := s.AddCategory("V", []string{"a", "e", "i", "aː"}); err != nil {
+		t.Fatalf("error when adding category")
+	}
+	if err := s.AddSegments([]string{"aː"}); err != nil {
+		t.Fatalf("error when adding segments")
+	}
+	if err := s.SetSyllableTemplate(template); err != nil {
+		t.Fatalf("error when setting syllable template: %s", err)
+	}
+	return s
+}
+
+func TestParseSyllableTemplate(t *testing.T) {
+	assert := assert.New(t)
+	s := newSyllableScago(t, "V")
+	template, err := s.ParseSyllableTemplate("(s) (C) V (C)")
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Equal(template.String(), "(s)(C)V(C)")
+	assert.Len(template.onset, 2)
+	assert.Equal(template.nucleus.category.Identifier(), "V")
+	assert.Len(template.coda, 1)
+	template, err = s.ParseSyllableTemplate("CV(C)")
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Len(template.onset, 1)
+	assert.Equal(template.nucleus.category.Identifier(), "V")
+	assert.Len(template.coda, 1)
+	template, err = s.ParseSyllableTemplate("CVV")
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Equal(template.nucleus.category.Identifier(), "C")
+	template, err = s.ParseSyllableTemplate("C<V>V")
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Equal(template.String(), "C<V>V")
+	assert.Len(template.onset, 1)
+	assert.Equal(template.nucleus.category.Identifier(), "V")
+	assert.Len(template.coda, 1)
+	for _, invalid := range []string{"", "(C)", "(C", "[^V", "C(xy)V", "<C", "<C><V>"} {
+		_, err := s.ParseSyllableTemplate(invalid)
+		assert.Error(err, invalid)
+	}
+}
+
+func TestSyllabify(t *testing.T) {
+	tests := []struct {
+		template string
+		word     string
+		want     []string
+	}{
+		{"(C)(C)V(C)", "pastra", []string{"pas", "tra"}},
+		{"(C)(C)V(C)", "antra", []string{"an", "tra"}},
+		{"(C)(C)V(C)", "aia", []string{"a", "i", "a"}},
+		{"(C)(C)V(C)", "ast", []string{"ast"}},
+		{"(C)(C)V(C)", "taːpa", []string{"taː", "pa"}},
+		{"(C)V(C)", "antra", []string{"ant", "ra"}},
+		{"(s)(C)V(C)", "astra", []string{"ast", "ra"}},
+		{"(s)(C)V(C)", "asta", []string{"a", "sta"}},
+		{"(C)V", "pst", []string{"pst"}},
+		{"CV(C)", "patak", []string{"pa", "tak"}},
+		{"CVC", "patak", []string{"pa", "tak"}},
+		{"C<V>(C)", "patak", []string{"pa", "tak"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.template+" "+tt.word, func(t *testing.T) {
+			assert := assert.New(t)
+			s := newSyllableScago(t, tt.template)
+			got, err := s.Syllabify(tt.word)
+			assert.NoError(err)
+			assert.Equal(got, tt.want)
+		})
+	}
+	t.Run("no template", func(t *testing.T) {
+		_, err := New().Syllabify("pata")
+		assert.Error(t, err)
+	})
+}
+
+func TestSyllableConditions(t *testing.T) {
+	tests := []struct {
+		rule string
+		word string
+		want string
+	}{
+		{"a > aː / _$", "pata", "paːtaː"},
+		{"a > aː / _$", "panta", "pantaː"},
+		{"t > d / $_", "tata", "dada"},
+		{"a > e / _t$", "patka", "petka"},
+		{"a > e / _tk", "patka", "petka"},
+		{"a > e / _(t)$", "patka", "petke"},
+		{"C > s / {coda}", "batka", "baska"},
+		{"C > s / {onset}", "batka", "satsa"},
+		{"V > e / {nucleus}", "bat", "bet"},
+		{"a > e / {1}", "patata", "petata"},
+		{"a > e / {-1}", "patata", "patate"},
+		{"a > e / {2}, _t", "patata", "pateta"},
+		{"a > e / _ ! {-1}", "patata", "peteta"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			assert := assert.New(t)
+			s := newSyllableScago(t, "(C)V(C)")
+			if !assert.NoError(s.AddRule(tt.rule)) {
+				return
+			}
+			got, err := s.Apply(tt.word)
+			assert.NoError(err)
+			assert.Equal(got, tt.want)
+		})
+	}
+	t.Run("resyllabification", func(t *testing.T) {
+		assert := assert.New(t)
+		s := newSyllableScago(t, "(C)V(C)")
+		// Once the e is lost, t is in the coda of the first syllable
+		assert.NoError(s.AddRule("e > / t_n"))
+		assert.NoError(s.AddRule("C > s / {coda}"))
+		got, err := s.Apply("patena")
+		assert.NoError(err)
+		assert.Equal(got, "pasna")
+	})
+	t.Run("invalid conditions", func(t *testing.T) {
+		assert := assert.New(t)
+		s := newSyllableScago(t, "(C)V(C)")
+		assert.Error(s.AddRule("a > e / {0}"))
+		assert.Error(s.AddRule("a > e / {coda2}"))
+		assert.Error(New().AddRule("a > e / _$"))
+		assert.Error(New().AddRule("a > e / {coda}"))
+	})
+}
+
+func TestExportSyllables(t *testing.T) {
+	assert := assert.New(t)
+	ruleset := "segments: aː\nV = a, aː\nsyllables: (s)(C)V(C)\na > aː / _$\n"
+	s := New()
+	assert.NoError(s.AddCategory("C", []string{"p", "t"}))
+	if !assert.NoError(s.LoadRuleset(strings.NewReader(ruleset))) {
+		return
+	}
+	sb := &strings.Builder{}
+	if !assert.NoError(s.Export(sb)) {
+		return
+	}
+	assert.Equal(sb.String(), "segments: aː\nC = p, t\nV = a, aː\nsyllables: (s)(C)V(C)\na > aː / _$\n")
+}
@@ -1,16 +1,66 @@
 package scago
 
 import (
+	"errors"
+	"fmt"
 	"regexp"
+	"regexp/syntax"
+	"strconv"
 	"strings"
 )
 
+// targetIndexPattern matches an index written at the end of a target,
+// e.g the "[-1]" in "a[-1]" or the "[2,3]" in "a[2,3]".
+var targetIndexPattern = regexp.MustCompile(`^(.*?)\[\s*(-?\d+(?:\s*,\s*-?\d+)*)\s*\]$`)
+
 // Target represents the target of a sound change, that is
 // the sounds or broad categories of sounds that are to be
-// targeted by a sound change.
-// TODO: add more features to target e.g indexing (nth instance of target)
+// targeted by a sound change. A target may be limited to the
+// nth instance(s) of itself in the word by an index.
 type Target struct {
-	pattern *regexp.Regexp // the pattern represented by the target
+	pattern  *regexp.Regexp // the pattern represented by the target
+	indices  []int          // if non-empty, the instances to target (1 is first, -1 is last)
+	category *Category      // if the target is a single category, that category
+	elements []string       // the targets as written, e.g "a" or "K"
+	first    *[256]bool     // the bytes a match of the pattern can start with, or nil if any
+}
+
+// String returns the target in canonical scago notation.
+func (t *Target) String() string {
+	if t == nil {
+		return ""
+	}
+	s := strings.Join(t.elements, ", ")
+	if len(t.indices) > 0 {
+		indices := make([]string, len(t.indices))
+		for i, index := range t.indices {
+			indices[i] = strconv.Itoa(index)
+		}
+		s += "[" + strings.Join(indices, ",") + "]"
+	}
+	return s
+}
+
+// Select returns the given occurrences of the target in a word
+// which are picked out by the target's indices, as a set. Returns
+// nil if the target has no indices, i.e every occurrence is
+// targeted.
+func (t *Target) Select(occurrences []int) map[int]bool {
+	if len(t.indices) == 0 {
+		return nil
+	}
+	selected := make(map[int]bool)
+	for _, i := range t.indices {
+		if i < 0 {
+			i += len(occurrences)
+		} else {
+			i--
+		}
+		if i >= 0 && i < len(occurrences) {
+			selected[occurrences[i]] = true
+		}
+	}
+	return selected
 }
 
 // ParseTarget returns a Target object based on a given input
@@ -25,21 +75,58 @@ func (s *Scago) ParseTarget(input string) (*Target, error) {
 	if input == "" {
 		return nil, nil
 	}
+	// Take any index off the end of the target
+	indices, input, err := parseTargetIndices(input)
+	if err != nil {
+		return nil, err
+	}
+	if input == "" {
+		return nil, errors.New("index given without a target")
+	}
 
 	sb := &strings.Builder{}
-	targets := strings.Split(input, ",")
+	targets := splitElements(input)
+	// Keep hold of the category if it's the only target, so that it
+	// can be mapped to another category by a change
+	var category *Category
+	if len(targets) == 1 {
+		category = s.GetCategory(strings.TrimSpace(targets[0]))
+		if isFeatureMatrix(strings.TrimSpace(targets[0])) {
+			category, err = s.featureCategory(strings.TrimSpace(targets[0]))
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	elements := make([]string, len(targets))
 	sb.WriteString("^(")
+	// The offset of each target in the input, not counting whitespace
+	at := 0
 	for i, target := range targets {
 		if i != 0 {
 			sb.WriteString("|")
+			at += countNonSpace(targets[i-1]) + 1
 		}
 		target = strings.TrimSpace(target)
+		elements[i] = target
 		// Append the category's pattern to the string if the
-		// target is a category identifier, otherwise just append
-		// the target.
+		// target is a category identifier or a feature matrix, or
+		// the pattern for any segment not in the category if it is
+		// negated (!V or [^V]), otherwise just append the target.
 		if c := s.GetCategory(target); c != nil {
 			sb.WriteString(c.pattern)
+		} else if isFeatureMatrix(target) {
+			c, err := s.featureCategory(target)
+			if err != nil {
+				return nil, errorAt(at, err)
+			}
+			sb.WriteString(c.pattern)
+		} else if negated, ok := s.negatedCategory(target); ok {
+			sb.WriteString(negated)
 		} else {
+			if _, err := regexp.Compile(target); err != nil {
+				return nil, errorAt(at, targetSyntaxError(target, err))
+			}
 			sb.WriteString(target)
 		}
 	}
@@ -48,7 +135,161 @@ func (s *Scago) ParseTarget(input string) (*Target, error) {
 	pattern := sb.String()
 	re, err := regexp.Compile(pattern)
 	if err != nil {
-		return nil, err
+		return nil, errors.New("invalid target")
+	}
+	// Prefer the longest possible target, e.g "ts" over "t" in (t|ts)
+	re.Longest()
+	return &Target{re, indices, category, elements, firstBytes(re)}, nil
+}
+
+// parseTargetIndices splits the index off the end of a target string,
+// returning the indices and the rest of the target. If the target has
+// no index, the indices are nil and the target is returned unchanged.
+func parseTargetIndices(input string) ([]int, string, error) {
+	parts := targetIndexPattern.FindStringSubmatchIndex(input)
+	if parts == nil {
+		return nil, input, nil
+	}
+	var indices []int
+	// The offset in the input of each index in turn
+	at := parts[4]
+	for _, index := range strings.Split(input[parts[4]:parts[5]], ",") {
+		i, err := strconv.Atoi(strings.TrimSpace(index))
+		if err != nil {
+			return nil, "", errorAt(countNonSpace(input[:at]), fmt.Errorf("target index %s is too large", strings.TrimSpace(index)))
+		}
+		if i == 0 {
+			return nil, "", errorAt(countNonSpace(input[:at]), fmt.Errorf("invalid target index %d", i))
+		}
+		indices = append(indices, i)
+		at += len(index) + 1
+	}
+	return indices, strings.TrimSpace(input[parts[2]:parts[3]]), nil
+}
+
+// targetSyntaxError returns a readable error in place of the error
+// the regexp package gave for the given target, found at the
+// character that caused it where that can be worked out.
+func targetSyntaxError(target string, err error) error {
+	var serr *syntax.Error
+	if !errors.As(err, &serr) {
+		return fmt.Errorf("invalid target %q", target)
+	}
+	switch serr.Code {
+	case syntax.ErrMissingParen, syntax.ErrUnexpectedParen:
+		open, closing := unbalancedParentheses(target)
+		if closing >= 0 {
+			return errorAt(countNonSpace(target[:closing]), errors.New("unexpected ) in target"))
+		}
+		return errorAt(countNonSpace(target[:max(open, 0)]), errors.New("missing ) in target"))
+	case syntax.ErrMissingBracket:
+		return errorAt(countNonSpace(target[:max(strings.LastIndex(target, "["), 0)]), errors.New("missing ] in target"))
+	case syntax.ErrMissingRepeatArgument:
+		return errorAt(countNonSpace(target[:max(strings.Index(target, serr.Expr), 0)]), fmt.Errorf("%s must follow an element in target", serr.Expr))
+	}
+	return errorAt(countNonSpace(target[:max(strings.Index(target, serr.Expr), 0)]), fmt.Errorf("invalid %s in target", serr.Expr))
+}
+
+// unbalancedParentheses returns the index in the given pattern of the
+// first ( that is never closed and of the first ) that closes nothing,
+// each -1 if there is none. Escaped parentheses and those in brackets
+// are left out.
+func unbalancedParentheses(pattern string) (int, int) {
+	var open []int
+	brackets := false
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case c == '\\':
+			i++
+		case brackets:
+			brackets = c != ']'
+		case c == '[':
+			brackets = true
+		case c == '(':
+			open = append(open, i)
+		case c == ')':
+			if len(open) == 0 {
+				return -1, i
+			}
+			open = open[:len(open)-1]
+		}
+	}
+	if len(open) == 0 {
+		return -1, -1
+	}
+	return open[0], -1
+}
+
+// firstBytes returns the set of bytes that a match of the given regexp
+// can start with, so that places in a word that the regexp can't match
+// can be skipped without running it. Returns nil if the regexp can
+// match the empty string, or if the set can't be worked out.
+func firstBytes(re *regexp.Regexp) *[256]bool {
+	tree, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil {
+		return nil
+	}
+	set := &[256]bool{}
+	nullable, ok := addFirstBytes(set, tree.Simplify())
+	if nullable || !ok {
+		return nil
+	}
+	return set
+}
+
+// addFirstBytes adds the bytes that a match of re can start with to
+// set, returning true if re can also match the empty string. Returns
+// false for ok if the bytes can't be worked out.
+func addFirstBytes(set *[256]bool, re *syntax.Regexp) (nullable bool, ok bool) {
+	switch re.Op {
+	case syntax.OpEmptyMatch, syntax.OpBeginLine, syntax.OpBeginText:
+		return true, true
+	case syntax.OpLiteral:
+		if len(re.Rune) == 0 {
+			return true, true
+		}
+		if re.Flags&syntax.FoldCase != 0 {
+			return false, false
+		}
+		set[string(re.Rune[0])[0]] = true
+		return false, true
+	case syntax.OpCharClass:
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			// Don't go through huge classes such as [^a] rune by rune
+			if re.Rune[i+1]-re.Rune[i] > 0xff {
+				return false, false
+			}
+			for r := re.Rune[i]; r <= re.Rune[i+1]; r++ {
+				set[string(r)[0]] = true
+			}
+		}
+		return false, true
+	case syntax.OpCapture, syntax.OpPlus:
+		return addFirstBytes(set, re.Sub[0])
+	case syntax.OpQuest, syntax.OpStar:
+		_, ok := addFirstBytes(set, re.Sub[0])
+		return true, ok
+	case syntax.OpRepeat:
+		nullable, ok := addFirstBytes(set, re.Sub[0])
+		return nullable || re.Min == 0, ok
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			nullable, ok := addFirstBytes(set, sub)
+			if !nullable || !ok {
+				return false, ok
+			}
+		}
+		return true, true
+	case syntax.OpAlternate:
+		nullable := false
+		for _, sub := range re.Sub {
+			n, ok := addFirstBytes(set, sub)
+			if !ok {
+				return false, false
+			}
+			nullable = nullable || n
+		}
+		return nullable, true
 	}
-	return &Target{re}, nil
+	return false, false
 }
@@ -1,6 +1,7 @@
 package scago
 
 import (
+	"regexp"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -46,4 +47,64 @@ func TestParseTarget(t *testing.T) {
 		assert.NoError(err)
 		assert.Equal(got.pattern.String(), "^((a|b|c)|a|b|c|d|(x|y|z)|e)")
 	})
+	t.Run("Indexed target", func(t *testing.T) {
+		assert := assert.New(t)
+		got, err := s.ParseTarget("K[-1]")
+		assert.NoError(err)
+		assert.Equal(got.pattern.String(), "^((a|b|c))")
+		assert.Equal(got.indices, []int{-1})
+	})
+	t.Run("Multiple indices on multiple targets", func(t *testing.T) {
+		assert := assert.New(t)
+		got, err := s.ParseTarget("a, b [2, 3]")
+		assert.NoError(err)
+		assert.Equal(got.pattern.String(), "^(a|b)")
+		assert.Equal(got.indices, []int{2, 3})
+	})
+	t.Run("Zero index", func(t *testing.T) {
+		_, err := s.ParseTarget("a[0]")
+		assert.Error(t, err)
+	})
+}
+
+func TestTargetSelect(t *testing.T) {
+	assert := assert.New(t)
+	occurrences := []int{1, 4, 6, 9}
+	assert.Nil((&Target{}).Select(occurrences))
+	assert.Equal((&Target{indices: []int{1}}).Select(occurrences), map[int]bool{1: true})
+	assert.Equal((&Target{indices: []int{-1}}).Select(occurrences), map[int]bool{9: true})
+	assert.Equal((&Target{indices: []int{2, -2}}).Select(occurrences), map[int]bool{4: true, 6: true})
+	assert.Empty((&Target{indices: []int{5}}).Select(occurrences))
+}
+
+func TestFirstBytes(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string // the bytes matches can start with, or "any"
+	}{
+		{"^(a)", "a"},
+		{"^(a|bc|d)", "abd"},
+		{"^((p|t|k))", "kpt"},
+		{"^([a-c]x)", "abc"},
+		{"^(a?b)", "ab"},
+		{"^(ə|e)", "e\xc9"},
+		{"^(a*)", "any"},
+		{"^(.)", "any"},
+		{"^([^a])", "any"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			got := "any"
+			if set := firstBytes(regexp.MustCompile(tt.pattern)); set != nil {
+				var bytes []byte
+				for b, ok := range set {
+					if ok {
+						bytes = append(bytes, byte(b))
+					}
+				}
+				got = string(bytes)
+			}
+			assert.Equal(t, got, tt.want)
+		})
+	}
 }
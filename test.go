@@ -0,0 +1,188 @@
+package scago
+
+import (
+	"errors"
+	"strings"
+)
+
+// Test represents an assertion that a word is changed into an expected
+// form, either by the whole ruleset or by a single rule. Tests can be
+// written in a ruleset so that it can be checked with RunTests.
+// The object forms part of a linked list via the next *Test,
+// which may be nil in case of being the last in the set.
+type Test struct {
+	input string // the word to change
+	want  string // the form the word should be changed into
+	rule  *Rule  // if not nil, the only rule the word is changed by
+	line  int    // the line of the ruleset the test was written on, or 0
+	next  *Test  // the next test in the linked list
+}
+
+// Input returns the word that t changes.
+func (t *Test) Input() string {
+	return t.input
+}
+
+// Want returns the form that t expects its word to be changed into.
+func (t *Test) Want() string {
+	return t.want
+}
+
+// Rule returns the only rule that t applies, or nil if t applies the
+// whole ruleset.
+func (t *Test) Rule() *Rule {
+	return t.rule
+}
+
+// Line returns the line of the ruleset that t was written on, or 0 if
+// it wasn't loaded from a ruleset.
+func (t *Test) Line() int {
+	return t.line
+}
+
+// String returns t in the notation it is written with after a test:
+// or example: line in a ruleset, e.g "kapa -> gaba".
+func (t *Test) String() string {
+	return t.input + " -> " + t.want
+}
+
+// HasNext returns true if t is followed by another test,
+// thus false if this is the last test in the linked list.
+func (t *Test) HasNext() bool {
+	return t.next != nil
+}
+
+// Append appends a test to the end of the linked list of tests.
+func (t *Test) Append(test *Test) {
+	if t.HasNext() {
+		t.next.Append(test)
+		return
+	}
+	t.next = test
+}
+
+// Failure is the outcome of a test that didn't give the form it
+// expected.
+type Failure struct {
+	Test  *Test  // the test that failed
+	Got   string // the form the word was changed into instead
+	Err   error  // the error that came up while changing the word, if any
+	Rule  *Rule  // the rule at which the derivation went wrong, if it could be found
+	Trace []Step // the derivation of the word, if the test applied the whole ruleset
+}
+
+// Tests returns the tests in s, in the order they were added.
+func (s *Scago) Tests() []*Test {
+	var tests []*Test
+	for t := s.tests; t != nil; t = t.next {
+		tests = append(tests, t)
+	}
+	return tests
+}
+
+// AddTest adds a test to s that the whole ruleset changes the given
+// word into the wanted form. Returns an error if either is blank.
+func (s *Scago) AddTest(input string, want string) error {
+	return s.addTest(input, want, nil)
+}
+
+// AddExample adds a test to s that the last rule added so far, on its
+// own, changes the given word into the wanted form. Returns an error
+// if either is blank, or if no rules have been added yet.
+func (s *Scago) AddExample(input string, want string) error {
+	var last *Rule
+	for r := s.rules; r != nil; r = r.next {
+		last = r
+	}
+	if last == nil {
+		return errors.New("example given before any rule")
+	}
+	return s.addTest(input, want, last)
+}
+
+// addTest adds a test to s that the given word is changed into the
+// wanted form, by the given rule or by the whole ruleset if nil.
+func (s *Scago) addTest(input string, want string, rule *Rule) error {
+	input = strings.TrimSpace(input)
+	want = strings.TrimSpace(want)
+	if input == "" {
+		return errors.New("test has no word")
+	}
+	if want == "" {
+		return errors.New("test has no expected form")
+	}
+	t := &Test{input: input, want: want, rule: rule}
+	if s.tests == nil {
+		s.tests = t
+	} else {
+		s.tests.Append(t)
+	}
+	return nil
+}
+
+// parseTest splits a test as written in a ruleset, e.g "kapa -> gaba",
+// into the word and its expected form. The arrow may also be written
+// as →.
+func parseTest(test string) (string, string, error) {
+	input, want, ok := strings.Cut(test, "->")
+	if !ok {
+		input, want, ok = strings.Cut(test, "→")
+	}
+	if !ok {
+		return "", "", errors.New("test must contain the -> operator")
+	}
+	return input, want, nil
+}
+
+// lastTest returns the last test in s, or nil if there are none.
+func (s *Scago) lastTest() *Test {
+	var last *Test
+	for t := s.tests; t != nil; t = t.next {
+		last = t
+	}
+	return last
+}
+
+// RunTests checks every test in s, and returns a Failure for each test
+// whose word wasn't changed into the form it expected, in the order
+// the tests were added. Returns nil if every test passed.
+//
+// For a test of the whole ruleset, the Failure gives the rule at which
+// the derivation went wrong, if there is one: the first rule that
+// changed the word such that the ruleset without it gives the expected
+// form. While looking for it, each such rule is switched off in turn,
+// so s must not be used by anything else while the tests run.
+func (s *Scago) RunTests() []Failure {
+	var failures []Failure
+	for t := s.tests; t != nil; t = t.next {
+		if t.rule != nil {
+			got, err := t.rule.Apply(t.input)
+			if err != nil || got != t.want {
+				failures = append(failures, Failure{Test: t, Got: got, Err: err, Rule: t.rule})
+			}
+			continue
+		}
+		got, trace, err := s.ApplyTrace(t.input)
+		if err == nil && got == t.want {
+			continue
+		}
+		failures = append(failures, Failure{t, got, err, s.divergence(t, trace), trace})
+	}
+	return failures
+}
+
+// divergence returns the first rule in the given derivation of the
+// test's word such that applying the ruleset without that rule gives
+// the expected form, or nil if there is no such rule.
+func (s *Scago) divergence(t *Test, trace []Step) *Rule {
+	for _, step := range trace {
+		r := step.Rule
+		r.disabled = true
+		got, err := s.Apply(t.input)
+		r.disabled = false
+		if err == nil && got == t.want {
+			return r
+		}
+	}
+	return nil
+}
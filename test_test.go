@@ -0,0 +1,116 @@
+package scago
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testRuleset = `P = p, t, k
+B = b, d, g
+V = a, e, i, o, u
+P > B / V_V
+example: apa -> aba
+b > w / a_a
+example: aba -> awa
+k > g / #_
+test: kapa -> gawa
+test: pita -> pida
+`
+
+func TestLoadTests(t *testing.T) {
+	assert := assert.New(t)
+	s := New()
+	if !assert.NoError(s.LoadRuleset(strings.NewReader(testRuleset))) {
+		return
+	}
+	tests := s.Tests()
+	if !assert.Len(tests, 4) {
+		return
+	}
+	rules := s.Rules()
+	assert.Equal(tests[0].Input(), "apa")
+	assert.Equal(tests[0].Want(), "aba")
+	assert.Same(tests[0].Rule(), rules[0])
+	assert.Equal(tests[0].Line(), 5)
+	assert.Same(tests[1].Rule(), rules[1])
+	assert.Nil(tests[2].Rule())
+	assert.Equal(tests[2].Line(), 9)
+	assert.Equal(tests[3].String(), "pita -> pida")
+	// Tests are written back with their rules
+	sb := &strings.Builder{}
+	assert.NoError(s.Export(sb))
+	assert.Equal(sb.String(), testRuleset)
+}
+
+func TestLoadTestsInvalid(t *testing.T) {
+	for _, ruleset := range []string{
+		"example: apa -> aba",
+		"a > e\ntest: apa aba",
+		"a > e\ntest: -> aba",
+		"a > e\ntest: apa →",
+	} {
+		t.Run(ruleset, func(t *testing.T) {
+			assert.Error(t, New().LoadRuleset(strings.NewReader(ruleset)))
+		})
+	}
+}
+
+func TestRunTests(t *testing.T) {
+	t.Run("passing", func(t *testing.T) {
+		assert := assert.New(t)
+		s := New()
+		assert.NoError(s.LoadRuleset(strings.NewReader(testRuleset)))
+		assert.Empty(s.RunTests())
+	})
+	t.Run("failing", func(t *testing.T) {
+		assert := assert.New(t)
+		s := New()
+		assert.NoError(s.LoadRuleset(strings.NewReader(testRuleset)))
+		// A rule inserted after the others are tested breaks kapa
+		assert.NoError(s.AddRule("g > ŋ / #_"))
+		assert.NoError(s.AddExample("ga", "ga"))
+		failures := s.RunTests()
+		if !assert.Len(failures, 2) {
+			return
+		}
+		rules := s.Rules()
+		assert.Equal(failures[0].Test.Input(), "kapa")
+		assert.Equal(failures[0].Got, "ŋawa")
+		assert.NoError(failures[0].Err)
+		assert.Same(failures[0].Rule, rules[3])
+		assert.Len(failures[0].Trace, 4)
+		assert.Equal(failures[1].Test.Input(), "ga")
+		assert.Equal(failures[1].Got, "ŋa")
+		assert.Same(failures[1].Rule, rules[3])
+		// The rule that was switched off to find where kapa went wrong
+		// is switched back on
+		assert.True(rules[3].Enabled())
+	})
+	t.Run("no single rule to blame", func(t *testing.T) {
+		assert := assert.New(t)
+		s := New()
+		assert.NoError(s.AddRule("a > e"))
+		assert.NoError(s.AddTest("pa", "po"))
+		failures := s.RunTests()
+		if !assert.Len(failures, 1) {
+			return
+		}
+		assert.Equal(failures[0].Got, "pe")
+		assert.Nil(failures[0].Rule)
+	})
+	t.Run("error", func(t *testing.T) {
+		assert := assert.New(t)
+		s := New()
+		assert.NoError(s.AddRule("a >"))
+		assert.NoError(s.AddRule("p > b"))
+		// The word is empty by the time the second rule is applied
+		assert.NoError(s.AddTest("a", "b"))
+		failures := s.RunTests()
+		if !assert.Len(failures, 1) {
+			return
+		}
+		assert.Error(failures[0].Err)
+	})
+}
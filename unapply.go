@@ -0,0 +1,183 @@
+package scago
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// UnapplyLimit is the maximum number of candidate forms Unapply will
+// consider before each rule before giving up.
+const UnapplyLimit = 10000
+
+// Unapply searches backwards through the Scago's ruleset for every
+// form of a word that Apply would change into the given word, and
+// returns them in alphabetical order. Each rule is undone by putting
+// back each sound of its target wherever the word has what the rule
+// would have changed it to, in every combination, and keeping only
+// the forms that the rule changes back into the word. Every form found
+// is then checked by applying the whole ruleset to it again.
+//
+// Rules without a target, or that move sounds or change stress, can't
+// be undone, so a word must already be as such a rule leaves it. Rules
+// that apply by chance are undone as though they always apply, and
+// rules that only apply to words with certain tags are skipped.
+// Targets that are negated categories or raw patterns are not put back
+// either.
+//
+// If more than UnapplyLimit forms come up before any rule, the search
+// stops, and the forms found so far are returned along with an error.
+func (s *Scago) Unapply(word string) ([]string, error) {
+	word, _ = SplitTags(word)
+	if word == "" {
+		return nil, errors.New("empty word given")
+	}
+	rules := s.Rules()
+	candidates := []string{word}
+	var err error
+	for i := len(rules) - 1; i >= 0 && err == nil; i-- {
+		r := rules[i]
+		if !r.Enabled() || len(r.include) > 0 {
+			continue
+		}
+		var found []string
+		for _, c := range candidates {
+			var ancestors []string
+			ancestors, err = s.unapplyRule(r, c, UnapplyLimit-len(found))
+			found = append(found, ancestors...)
+			if err != nil {
+				break
+			}
+		}
+		candidates = found
+	}
+	// Only keep the forms that really do give the word
+	var ancestors []string
+	for _, c := range candidates {
+		if slices.Contains(ancestors, c) {
+			continue
+		}
+		if result, applyErr := s.apply(c, &dice{certain: true}, nil, nil); applyErr == nil && result == word {
+			ancestors = append(ancestors, c)
+		}
+	}
+	slices.Sort(ancestors)
+	return ancestors, err
+}
+
+// unapplyRule returns every form that r changes into the given word,
+// which may include the word itself, considering at most limit forms.
+// If more forms than that come up, the forms found so far are
+// returned along with an error.
+func (s *Scago) unapplyRule(r *Rule, word string, limit int) ([]string, error) {
+	replacements := s.replacements(r)
+	seen := map[string]bool{word: true}
+	queue := []string{word}
+	var ancestors []string
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+		result, err := r.apply(c, &dice{certain: true})
+		applies := err == nil && result == word
+		if applies {
+			ancestors = append(ancestors, c)
+		}
+		// A form the rule doesn't change into the word can only lead
+		// to more such forms, except for the word itself
+		if !applies && c != word {
+			continue
+		}
+		for _, rp := range replacements {
+			for _, ancestor := range s.segmenter.undo(c, rp) {
+				if seen[ancestor] {
+					continue
+				}
+				if len(seen) >= limit {
+					return ancestors, fmt.Errorf("more than %d possible forms of %s", UnapplyLimit, word)
+				}
+				seen[ancestor] = true
+				queue = append(queue, ancestor)
+			}
+		}
+	}
+	return ancestors, nil
+}
+
+// replacement is something a rule could change into something else.
+type replacement struct {
+	from string // what the rule's target matched
+	to   string // what the rule changed it into
+}
+
+// replacements returns every replacement that r could make, for each
+// sound of its target that can be listed. Returns none if r has no
+// target, moves sounds or changes stress, since such changes can't be
+// undone by replacing anything.
+func (s *Scago) replacements(r *Rule) []replacement {
+	if r.target == nil {
+		return nil
+	}
+	sounds := s.targetSounds(r.target)
+	var replacements []replacement
+	for _, c := range []*Change{r.change, r.alternative} {
+		if c == nil || c.movement != 0 || c.stress != Unstressed {
+			continue
+		}
+		for _, from := range sounds {
+			to := ""
+			if !c.deletion {
+				to = c.Replace(from)
+			}
+			rp := replacement{from, to}
+			if from != to && !slices.Contains(replacements, rp) {
+				replacements = append(replacements, rp)
+			}
+		}
+	}
+	return replacements
+}
+
+// targetSounds returns the sounds matched by each element of the given
+// target that can be listed, i.e literal sounds and the sounds of
+// categories and feature matrices.
+func (s *Scago) targetSounds(t *Target) []string {
+	var sounds []string
+	for _, element := range t.elements {
+		if c := s.GetCategory(element); c != nil {
+			sounds = append(sounds, c.sounds...)
+		} else if isFeatureMatrix(element) {
+			if c, err := s.featureCategory(element); err == nil {
+				sounds = append(sounds, c.sounds...)
+			}
+		} else if regexp.QuoteMeta(element) == element {
+			sounds = append(sounds, element)
+		}
+	}
+	return sounds
+}
+
+// undo returns every form of word in which one occurrence of what rp
+// changes into, starting and ending at a segment boundary, is put back
+// to what it was changed from. If rp is a deletion, what was deleted
+// is put back at every segment boundary instead.
+func (sg *Segmenter) undo(word string, rp replacement) []string {
+	// The byte offsets of the segment boundaries in the word
+	boundaries := []int{0}
+	for _, segment := range sg.Segment(word) {
+		boundaries = append(boundaries, boundaries[len(boundaries)-1]+len(segment))
+	}
+	var forms []string
+	for _, i := range boundaries {
+		if rp.to == "" {
+			forms = append(forms, word[:i]+rp.from+word[i:])
+			continue
+		}
+		if !strings.HasPrefix(word[i:], rp.to) || !slices.Contains(boundaries, i+len(rp.to)) {
+			continue
+		}
+		forms = append(forms, word[:i]+rp.from+word[i+len(rp.to):])
+	}
+	return forms
+}
@@ -0,0 +1,74 @@
+package scago
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnapply(t *testing.T) {
+	tests := []struct {
+		name    string
+		ruleset string
+		word    string
+		want    []string
+	}{
+		{"no rules", "", "pata", []string{"pata"}},
+		{"unconditional merger", "o > a", "pata", []string{"pata", "pato", "pota", "poto"}},
+		{"conditioned change", "a > e / _#", "pate", []string{"pata", "pate"}},
+		{"word the rule can't give", "a > e / _#", "pata", []string{}},
+		{"category mapping", "P = p,t\nB = b,d\nP > B / a_a", "adab", []string{"adab", "atab"}},
+		{"deletion", "h > / _#", "pa", []string{"pa", "pah"}},
+		{"several rules", "p > f\na > e / _#\nf > v / e_#", "fate", []string{"fata", "fate", "pata", "pate"}},
+		{"simultaneous", "a > b / a_ ; simultaneous", "abbb", []string{"aaaa", "aaab", "aabb", "abbb"}},
+		{"exception", "except: pata\na > e / _#", "pate", []string{"pate"}},
+		// pat also gives pta, but movements can't be undone
+		{"movement", "a > @1", "pta", []string{"pta"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			s := New()
+			if !assert.NoError(s.LoadRuleset(strings.NewReader(tt.ruleset))) {
+				return
+			}
+			got, err := s.Unapply(tt.word)
+			if !assert.NoError(err) {
+				return
+			}
+			if len(tt.want) == 0 {
+				assert.Empty(got)
+			} else {
+				assert.Equal(got, tt.want)
+			}
+			// Every ancestor must give the word back
+			for _, ancestor := range got {
+				result, err := s.Apply(ancestor)
+				assert.NoError(err)
+				assert.Equal(result, tt.word)
+			}
+		})
+	}
+	t.Run("limit", func(t *testing.T) {
+		assert := assert.New(t)
+		s := New()
+		assert.NoError(s.AddRule("h >"))
+		got, err := s.Unapply("pa")
+		assert.Error(err)
+		assert.NotEmpty(got)
+	})
+	t.Run("chance", func(t *testing.T) {
+		assert := assert.New(t)
+		s := New()
+		assert.NoError(s.AddRule("a > e / _# ; chance=10%"))
+		assert.NoError(s.AddRule("p > b ; wordchance=10%"))
+		got, err := s.Unapply("bate")
+		assert.NoError(err)
+		assert.Equal(got, []string{"bata", "bate", "pata", "pate"})
+	})
+	t.Run("empty word", func(t *testing.T) {
+		_, err := New().Unapply(" ")
+		assert.Error(t, err)
+	})
+}
@@ -1,10 +1,10 @@
 package scago
 
 import (
-	"errors"
-	"fmt"
 	"regexp"
+	"slices"
 	"strings"
+	"unicode/utf8"
 )
 
 // Word represents a word as can be manipulated by sound changes,
@@ -12,26 +12,111 @@ import (
 // It also handles word-initial and word-final checks by appending
 // and prepending the # character to the word in Word's internal
 // representation. Word also has an internal counter to keep track
-// of how far along the word checking/compilation is.
+// of how far along the word checking/compilation is, and remembers
+// which position in the original word each segment came from.
+// A Word is iterated from left to right with Next, or from right to
+// left with Prev after calling Reverse.
 type Word struct {
-	internal []string
-	index    int
+	internal  []string
+	origin    []int    // index in the original word of each segment in internal
+	stress    []Stress // the stress marked on each segment in internal
+	index     int
+	reverse   bool               // true if the word is being iterated from right to left
+	template  *SyllableTemplate  // the template to split the word into syllables by, if any
+	syllables []int              // the syllable of each segment in internal, or nil if not worked out yet
+	positions []SyllablePosition // the position in its syllable of each segment in internal
+	text      string             // the segments in internal joined together, which patterns are matched against
+	offsets   []int              // the byte offset in text of each segment in internal, followed by the length of text
+	segmenter *Segmenter         // splits the word into segments again after it changes
+	changed   bool               // true if the word has changed since it was last split into segments
+	form      string             // the word as given by String, if formed is true
+	formed    bool               // true if form is up to date with the word
+}
+
+// join joins the segments of w together into the text that patterns
+// are matched against, noting the offset at which each segment starts.
+func (w *Word) join() {
+	sb := &strings.Builder{}
+	w.offsets = make([]int, 0, len(w.internal)+1)
+	for _, segment := range w.internal {
+		w.offsets = append(w.offsets, sb.Len())
+		sb.WriteString(segment)
+	}
+	w.offsets = append(w.offsets, sb.Len())
+	w.text = sb.String()
+}
+
+// segmentAt returns the index of the segment in w that starts at the
+// given byte offset in its text, where the end of the text counts as
+// the start of the segment after the last. Returns false if the
+// offset is partway through a segment.
+func (w *Word) segmentAt(offset int) (int, bool) {
+	return slices.BinarySearch(w.offsets, offset)
+}
+
+// current returns w as a string like String, only building it again
+// if w has changed since it was last asked for.
+func (w *Word) current() string {
+	if !w.formed {
+		w.form = w.String()
+		w.formed = true
+	}
+	return w.form
+}
+
+// load replaces w with the given word, split into segments by the
+// segmenter w was split by.
+func (w *Word) load(lemma string) error {
+	fresh, err := w.segmenter.NewWord(lemma)
+	if err != nil {
+		return err
+	}
+	*w = *fresh
+	return nil
+}
+
+// rewind readies w for a rule to be applied to it from the start, as
+// if it had just been made from its current form by NewWord. The word
+// is only split into segments again if it has changed since it last
+// was, since a change may have left a segment that the segmenter
+// would split differently, and the origin of each segment is reset.
+func (w *Word) rewind() error {
+	if w.changed {
+		if err := w.load(w.current()); err != nil {
+			return err
+		}
+	}
+	w.index = 0
+	w.reverse = false
+	for i := range w.origin {
+		w.origin[i] = i
+	}
+	return nil
 }
 
 // CheckConditions loops through a linked list of conditions
 // and checks if they apply, returning true if so and false if not.
 func (w *Word) CheckConditions(c *Condition, length int) bool {
 	for ; c != nil; c = c.next {
-		if c.global && !w.MatchGlobal(c.pattern) {
+		if c.global && !w.matchGlobal(c.pattern, c.syllabic) {
 			return false
 		} else {
-			if c.pre != nil && !w.MatchPre(c.pre) {
+			if c.pre != nil && !w.matchPre(c.pre, c.syllabic) {
 				return false
 			}
-			if c.post != nil && !w.MatchPost(c.post, length) {
+			if c.post != nil && !w.matchPost(c.post, length, c.syllabic) {
 				return false
 			}
 		}
+		if c.position != 0 && w.Position() != c.position {
+			return false
+		}
+		if c.syllable != 0 && !w.InSyllable(c.syllable) {
+			return false
+		}
+		if len(c.stresses) > 0 && !slices.Contains(c.stresses, w.SyllableStress()) {
+			return false
+		}
 	}
 	return true
 }
@@ -41,15 +126,36 @@ func (w *Word) CheckConditions(c *Condition, length int) bool {
 // many characters from the current index in the word needs to be
 // altered.
 func (w *Word) Change(change *Change, length int) error {
+	// The word will need to be split into syllables again, and its
+	// text joined back together once it has changed
+	w.syllables = nil
+	w.changed = true
+	w.formed = false
+	defer w.join()
 	original := make([]string, len(w.internal))
 	copy(original, w.internal)
+	originalOrigin := make([]int, len(w.origin))
+	copy(originalOrigin, w.origin)
+	originalStress := make([]Stress, len(w.stress))
+	copy(originalStress, w.stress)
+	if change.stress != Unstressed {
+		// Only the stress of the target's syllable changes
+		w.setSyllableStress(change.stress)
+		return nil
+	}
 	if change.deletion {
 		// Delete length amount of characters from current index in word
 		w.internal = original[:w.index]
 		w.internal = append(w.internal, original[w.index+length:]...)
+		w.origin = originalOrigin[:w.index]
+		w.origin = append(w.origin, originalOrigin[w.index+length:]...)
+		w.stress = originalStress[:w.index]
+		w.stress = append(w.stress, originalStress[w.index+length:]...)
 		// if we don't do the below, the next Next() will skip past the
-		// first character after deletion
-		w.index--
+		// first character after deletion (the next Prev() is unaffected)
+		if !w.reverse {
+			w.index--
+		}
 	} else {
 		// Trim movement if we're too close to the end of the word
 		movement := change.movement
@@ -61,11 +167,14 @@ func (w *Word) Change(change *Change, length int) error {
 			}
 		}
 		// Find original target for movement, or replacement for replacement
-		var replacement string
-		if change.replacement != "" {
-			replacement = change.replacement
-		} else {
-			replacement = strings.Join(w.internal[w.index:w.index+length], "")
+		replacement := change.Replace(strings.Join(w.internal[w.index:w.index+length], ""))
+		// The replacement takes the place of the target, so it is
+		// considered to come from where the target started.
+		replacementOrigin := originalOrigin[w.index]
+		// It also keeps any stress the target had
+		replacementStress := Unstressed
+		for _, stress := range originalStress[w.index : w.index+length] {
+			replacementStress = max(replacementStress, stress)
 		}
 		// Rebuild the internal representation from our copy of its previous state,
 		// based on the movement and replacements that need to happen for this Change.
@@ -73,20 +182,52 @@ func (w *Word) Change(change *Change, length int) error {
 			w.internal = nil
 			w.internal = append(w.internal, original[:w.index+movement]...)
 			w.internal = append(w.internal, replacement)
-			w.internal = append(w.internal, original[w.index+movement:w.index+movement+length]...)
+			w.internal = append(w.internal, original[w.index+movement:w.index]...)
 			w.internal = append(w.internal, original[w.index+length:]...)
-			fmt.Println("internal (after) =", strings.Join(w.internal, ""))
+			w.origin = nil
+			w.origin = append(w.origin, originalOrigin[:w.index+movement]...)
+			w.origin = append(w.origin, replacementOrigin)
+			w.origin = append(w.origin, originalOrigin[w.index+movement:w.index]...)
+			w.origin = append(w.origin, originalOrigin[w.index+length:]...)
+			w.stress = nil
+			w.stress = append(w.stress, originalStress[:w.index+movement]...)
+			w.stress = append(w.stress, replacementStress)
+			w.stress = append(w.stress, originalStress[w.index+movement:w.index]...)
+			w.stress = append(w.stress, originalStress[w.index+length:]...)
+			// Like below, when iterating from right to left, skip to the
+			// moved target so that it isn't found again
+			if w.reverse {
+				w.index += movement
+			}
 		} else {
 			w.internal = nil
 			w.internal = append(w.internal, original[:w.index]...)
 			w.internal = append(w.internal, original[w.index+length:w.index+movement+length]...)
 			w.internal = append(w.internal, replacement)
 			w.internal = append(w.internal, original[w.index+length+movement:]...)
+			w.origin = nil
+			w.origin = append(w.origin, originalOrigin[:w.index]...)
+			w.origin = append(w.origin, originalOrigin[w.index+length:w.index+movement+length]...)
+			w.origin = append(w.origin, replacementOrigin)
+			w.origin = append(w.origin, originalOrigin[w.index+length+movement:]...)
+			w.stress = nil
+			w.stress = append(w.stress, originalStress[:w.index]...)
+			w.stress = append(w.stress, originalStress[w.index+length:w.index+movement+length]...)
+			w.stress = append(w.stress, replacementStress)
+			w.stress = append(w.stress, originalStress[w.index+length+movement:]...)
 			// Stop it from unintentionally moving this again by finding it next iteration
 			// NB: this does mean that in e.g "apopiiii" with p>@4, the second 'p' will be
 			// ignored. This is not good but can be fixed in future - it seems like a fairly
 			// slim use case.
-			w.index += movement
+			if !w.reverse {
+				w.index += movement
+			}
+			// Likewise, step past the segment that something was
+			// inserted before, so that nothing is inserted before it
+			// again
+			if length == 0 && movement == 0 && !w.reverse {
+				w.index++
+			}
 		}
 	}
 	return nil
@@ -95,43 +236,283 @@ func (w *Word) Change(change *Change, length int) error {
 // MatchTarget checks whether the given regexp expression matches
 // against the current subsection of the word (without checking
 // boundary markers). If it does, returns the length of the match
-// and if not returns -1.
+// in segments and if not returns -1. A match that ends partway
+// through a segment does not count.
 func (w *Word) MatchTarget(re *regexp.Regexp) int {
-	match := re.FindStringIndex(w.Substring())
-	if match == nil {
+	return w.matchTarget(re, nil)
+}
+
+// matchTarget is MatchTarget, first checking that the current segment
+// starts with one of the bytes in first (if not nil) that a match of
+// the regexp can start with.
+func (w *Word) matchTarget(re *regexp.Regexp, first *[256]bool) int {
+	if w.index >= len(w.internal)-1 {
+		return -1
+	}
+	start := w.offsets[w.index]
+	if first != nil && !first[w.text[start]] {
+		return -1
+	}
+	length := matchBeginning(re, w.text[start:w.offsets[len(w.internal)-1]], func(offset int) bool {
+		_, ok := w.segmentAt(start + offset)
+		return ok
+	})
+	if length < 0 {
 		return -1
-	} else if match[0] == 0 {
-		return match[1]
 	}
-	return -1
+	end, _ := w.segmentAt(start + length)
+	return end - w.index
+}
+
+// Occurrences returns the indices of the segments at which each
+// non-overlapping match of the given regexp expression begins in
+// the word, scanning from the start of the word. The current index
+// of w is left unchanged.
+func (w *Word) Occurrences(re *regexp.Regexp) []int {
+	var occurrences []int
+	index := w.index
+	defer func() { w.index = index }()
+	w.index = 0
+	for w.Next() {
+		if t := w.MatchTarget(re); t > 0 {
+			occurrences = append(occurrences, w.index)
+			w.index += t - 1
+		}
+	}
+	return occurrences
+}
+
+// Origin returns the index in the original word of the segment at
+// w's current index.
+func (w *Word) Origin() int {
+	if w.index >= len(w.origin) {
+		return -1
+	}
+	return w.origin[w.index]
+}
+
+// Seek moves w's internal index to the segment that came from the
+// given index in the original word, returning false (and leaving the
+// index unchanged) if no such segment remains in the word.
+func (w *Word) Seek(origin int) bool {
+	for i, o := range w.origin {
+		if o == origin {
+			w.index = i
+			return true
+		}
+	}
+	return false
 }
 
 // MatchGlobal checks whether the given regexp expression matches
-// the entire word. Returns true if so, and false if not.
+// anywhere in the entire word, without starting or ending partway
+// through a segment. Returns true if so, and false if not.
 func (w *Word) MatchGlobal(re *regexp.Regexp) bool {
-	return re.MatchString(w.BoundaryString())
+	return w.matchGlobal(re, false)
 }
 
 // MatchPre checks whether the given regexp expression matches the
 // portion of the word (including boundary markers) prior to the
-// current index.
+// current index. The match must not start partway through a segment.
 func (w *Word) MatchPre(re *regexp.Regexp) bool {
-	pre := w.PreString()
-	if pre == "" {
-		return false
-	}
-	return re.MatchString(pre)
+	return w.matchPre(re, false)
 }
 
 // MatchPost checks whether the given regexp expression matches the
 // portion of the word (including boundary markers) after the current
-// index.
+// index. The match must not end partway through a segment.
 func (w *Word) MatchPost(re *regexp.Regexp, length int) bool {
-	post := w.PostString(length)
-	if post == "" {
+	return w.matchPost(re, length, false)
+}
+
+// matchGlobal is MatchGlobal, matching against the word with its
+// syllable boundaries marked if syllabic is true.
+func (w *Word) matchGlobal(re *regexp.Regexp, syllabic bool) bool {
+	if !syllabic {
+		for _, match := range re.FindAllStringIndex(w.text, -1) {
+			_, startOk := w.segmentAt(match[0])
+			_, endOk := w.segmentAt(match[1])
+			if startOk && endOk {
+				return true
+			}
+		}
+		return false
+	}
+	pieces := w.pieces(0, len(w.internal), syllabic)
+	for _, match := range re.FindAllStringIndex(strings.Join(pieces, ""), -1) {
+		start := segmentCount(pieces, match[0])
+		if start >= 0 && segmentCount(pieces[start:], match[1]-match[0]) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPre is MatchPre, matching against the word with its syllable
+// boundaries marked if syllabic is true.
+func (w *Word) matchPre(re *regexp.Regexp, syllabic bool) bool {
+	if w.index >= len(w.internal) || w.index == 0 {
+		return false
+	}
+	if !syllabic {
+		return matchEnding(re, w.text[:w.offsets[w.index]], func(offset int) bool {
+			_, ok := w.segmentAt(offset)
+			return ok
+		})
+	}
+	pieces := w.pieces(0, w.index, syllabic)
+	return matchEnding(re, strings.Join(pieces, ""), func(offset int) bool {
+		return segmentCount(pieces, offset) >= 0
+	})
+}
+
+// matchEnding returns true if the given regexp expression, which is
+// anchored to the end of the text, matches the text from an offset
+// that start returns true for. Every match is tried in turn rather
+// than only the leftmost, since that may start partway through a
+// segment where a later one doesn't.
+func matchEnding(re *regexp.Regexp, text string, start func(int) bool) bool {
+	for from := 0; from <= len(text); {
+		match := re.FindStringIndex(text[from:])
+		if match == nil {
+			return false
+		}
+		offset := from + match[0]
+		if start(offset) {
+			return true
+		}
+		_, size := utf8.DecodeRuneInString(text[offset:])
+		from = offset + max(size, 1)
+	}
+	return false
+}
+
+// matchPost is MatchPost, matching against the word with its syllable
+// boundaries marked if syllabic is true.
+func (w *Word) matchPost(re *regexp.Regexp, length int, syllabic bool) bool {
+	if w.index+length >= len(w.internal) {
 		return false
 	}
-	return re.MatchString(post)
+	if !syllabic {
+		start := w.offsets[w.index+length]
+		return matchBeginning(re, w.text[start:], func(offset int) bool {
+			_, ok := w.segmentAt(start + offset)
+			return ok
+		}) >= 0
+	}
+	pieces := w.pieces(w.index+length, len(w.internal), syllabic)
+	return matchBeginning(re, strings.Join(pieces, ""), func(offset int) bool {
+		return segmentCount(pieces, offset) >= 0
+	}) >= 0
+}
+
+// matchBeginning is like matchEnding for a regexp expression anchored
+// to the start of the text, returning the length of the match, or -1
+// if there is none. Each shorter match is tried in turn after the
+// one the regexp prefers, since that may end partway through a
+// segment where a shorter one doesn't.
+func matchBeginning(re *regexp.Regexp, text string, end func(int) bool) int {
+	for to := len(text); ; {
+		match := re.FindStringIndex(text[:to])
+		if match == nil || match[0] != 0 {
+			return -1
+		}
+		if end(match[1]) {
+			return match[1]
+		}
+		if match[1] == 0 {
+			return -1
+		}
+		_, size := utf8.DecodeLastRuneInString(text[:match[1]])
+		to = match[1] - size
+	}
+}
+
+// pieces returns the segments of w from index from up to (but not
+// including) index to. If syllabic is true, a $ is put at each
+// syllable boundary within them, as well as at any boundary just
+// before the first segment or just after the last.
+func (w *Word) pieces(from int, to int, syllabic bool) []string {
+	if !syllabic {
+		return w.internal[from:to]
+	}
+	var pieces []string
+	for i := from; i <= to && i < len(w.internal); i++ {
+		if w.syllableBoundary(i) {
+			pieces = append(pieces, "$")
+		}
+		if i < to {
+			pieces = append(pieces, w.internal[i])
+		}
+	}
+	return pieces
+}
+
+// syllableBoundary returns true if the segment at index i starts a
+// syllable other than the first in the word.
+func (w *Word) syllableBoundary(i int) bool {
+	w.syllabify()
+	return i > 1 && i < len(w.internal)-1 && w.syllables[i] != w.syllables[i-1]
+}
+
+// syllabify splits w into syllables by its template, if it hasn't
+// been already since it last changed. The word boundaries are in no
+// syllable, and without a template the word is a single syllable.
+func (w *Word) syllabify() {
+	if w.syllables != nil {
+		return
+	}
+	segments := w.internal[1 : len(w.internal)-1]
+	var syllables []int
+	var positions []SyllablePosition
+	if w.template != nil {
+		syllables, positions = w.template.Syllabify(segments)
+	} else {
+		syllables = make([]int, len(segments))
+		positions = make([]SyllablePosition, len(segments))
+	}
+	w.syllables = append(append([]int{-1}, syllables...), -1)
+	w.positions = append(append([]SyllablePosition{0}, positions...), 0)
+}
+
+// Syllable returns the number of the syllable that the segment at w's
+// current index is in, counting from 1, or 0 if the index is at a
+// word boundary.
+func (w *Word) Syllable() int {
+	w.syllabify()
+	if w.index <= 0 || w.index >= len(w.internal)-1 {
+		return 0
+	}
+	return w.syllables[w.index] + 1
+}
+
+// SyllableCount returns the number of syllables in w.
+func (w *Word) SyllableCount() int {
+	w.syllabify()
+	if len(w.internal) <= 2 {
+		return 0
+	}
+	return w.syllables[len(w.internal)-2] + 1
+}
+
+// InSyllable returns true if the segment at w's current index is in
+// the nth syllable of w, counting from 1 at the start of the word, or
+// from -1 at the end of the word if n is negative.
+func (w *Word) InSyllable(n int) bool {
+	if n < 0 {
+		n += w.SyllableCount() + 1
+	}
+	return n > 0 && w.Syllable() == n
+}
+
+// Position returns the position in its syllable of the segment at w's
+// current index, or 0 if the index is at a word boundary.
+func (w *Word) Position() SyllablePosition {
+	w.syllabify()
+	if w.index < 0 || w.index >= len(w.internal) {
+		return 0
+	}
+	return w.positions[w.index]
 }
 
 // Next increments w's internal index and returns a bool which is
@@ -145,12 +526,52 @@ func (w *Word) Next() bool {
 	return true
 }
 
+// Prev decrements w's internal index and returns a bool which is
+// true if the resulting current character of the internal word is
+// valid (i.e not the start of the word or a word boundary). It is
+// used to iterate a word from right to left after calling Reverse.
+func (w *Word) Prev() bool {
+	w.index--
+	if w.index <= 0 || w.internal[w.index] == "#" {
+		return false
+	}
+	return true
+}
+
+// nextGap is like Next, except that it also stops at the word boundary
+// at the end of the word, so that something can be inserted before it.
+func (w *Word) nextGap() bool {
+	w.index++
+	if w.index >= len(w.internal) {
+		return false
+	}
+	return w.index == len(w.internal)-1 || w.internal[w.index] != "#"
+}
+
+// prevGap is like Prev, except that it also stops at the word boundary
+// at the end of the word, so that something can be inserted before it.
+// The index must start after that boundary.
+func (w *Word) prevGap() bool {
+	w.index--
+	if w.index <= 0 {
+		return false
+	}
+	return w.index == len(w.internal)-1 || w.internal[w.index] != "#"
+}
+
+// Reverse prepares w to be iterated from right to left with Prev,
+// by moving its internal index to the end of the word.
+func (w *Word) Reverse() {
+	w.reverse = true
+	w.index = len(w.internal) - 1
+}
+
 // Substring returns the Word as a substring, starting from the current
 // index and ending before the word boundary. Returns an empty string
 // if the internal index has passed all characters in the word.
 func (w *Word) Substring() string {
 	if w.index < len(w.internal)-1 {
-		return strings.Join(w.internal[w.index:len(w.internal)-1], "")
+		return w.text[w.offsets[w.index]:w.offsets[len(w.internal)-1]]
 	}
 	return ""
 }
@@ -161,7 +582,7 @@ func (w *Word) PreString() string {
 	if w.index >= len(w.internal) {
 		return ""
 	}
-	return strings.Join(w.internal[:w.index], "")
+	return w.text[:w.offsets[w.index]]
 }
 
 // PostString returns the Word as a substring (with boundary markers)
@@ -170,32 +591,31 @@ func (w *Word) PostString(length int) string {
 	if w.index+length >= len(w.internal) {
 		return ""
 	}
-	return strings.Join(w.internal[w.index+length:], "")
+	return w.text[w.offsets[w.index+length]:]
 }
 
 // String returns the Word as a full string, without the word-boundary
-// markers (#). In an unchanged word, this is the equivalent of
-// accessing the original string given to the constructor/
+// markers (#) but with any stress marks (ˈ and ˌ) in front of the
+// stressed syllables. In an unchanged word, this is the equivalent of
+// accessing the original string given to the constructor.
 func (w *Word) String() string {
-	return strings.Join(w.internal[1:len(w.internal)-1], "")
+	sb := &strings.Builder{}
+	for i := 1; i < len(w.internal)-1; i++ {
+		sb.WriteString(w.stressMark(i))
+		sb.WriteString(w.internal[i])
+	}
+	return sb.String()
 }
 
 // BoundaryString returns the entire word including boundary markers (#)
 // as a string.
 func (w *Word) BoundaryString() string {
-	return strings.Join(w.internal, "")
+	return w.text
 }
 
 // NewWord returns a new Word object based on the given word as a
-// string. It automatically prepends and appends the # marker.
+// string, split into grapheme clusters. It automatically prepends
+// and appends the # marker.
 func NewWord(lemma string) (*Word, error) {
-	lemma = strings.TrimSpace(lemma)
-	if lemma == "" {
-		return nil, errors.New("empty word given")
-	}
-	var sb strings.Builder
-	sb.WriteString("#")
-	sb.WriteString(lemma)
-	sb.WriteString("#")
-	return &Word{strings.Split(sb.String(), ""), 0}, nil
+	return (*Segmenter)(nil).NewWord(lemma)
 }
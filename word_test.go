@@ -98,6 +98,20 @@ func TestNext(t *testing.T) {
 	assert.False(w.Next())
 }
 
+func TestPrev(t *testing.T) {
+	assert := assert.New(t)
+	w, err := NewWord("pineapple")
+	if err != nil {
+		t.Fatalf("NewWord returned error: %s", err)
+	}
+	w.Reverse()
+	for i := 9; w.Prev(); i-- {
+		assert.Equal(w.index, i)
+	}
+	assert.Equal(w.index, 0)
+	assert.False(w.Prev())
+}
+
 func TestMatchTarget(t *testing.T) {
 	assert := assert.New(t)
 	w, err := NewWord("pineapple")
@@ -176,3 +190,62 @@ func TestMatchPre(t *testing.T) {
 	assert.False(w.MatchPre(re3))
 	assert.True(w.MatchPre(re4))
 }
+
+func TestOccurrences(t *testing.T) {
+	assert := assert.New(t)
+	w, err := NewWord("pineapple")
+	if err != nil {
+		t.Fatalf("NewWord returned error: %s", err)
+	}
+	assert.Equal(w.Occurrences(regexp.MustCompile(`^(p)`)), []int{1, 6, 7})
+	assert.Equal(w.Occurrences(regexp.MustCompile(`^(pp|e)`)), []int{4, 6, 9})
+	assert.Nil(w.Occurrences(regexp.MustCompile(`^(x)`)))
+	assert.Equal(w.index, 0)
+}
+
+func TestOrigin(t *testing.T) {
+	assert := assert.New(t)
+	w, err := NewWord("pineapple")
+	if err != nil {
+		t.Fatalf("NewWord returned error: %s", err)
+	}
+	assert.True(w.Next()) // #_pineapple#
+	assert.True(w.Next()) // #p_ineapple#
+	assert.NoError(w.Change(&Change{deletion: true}, 1))
+	assert.True(w.Next()) // #p_neapple#
+	assert.Equal(w.Origin(), 3)
+	assert.NoError(w.Change(&Change{replacement: "m"}, 1))
+	assert.True(w.Next()) // #pm_eapple#
+	assert.Equal(w.Origin(), 4)
+}
+
+func TestChange(t *testing.T) {
+	t.Run("replacement", func(t *testing.T) {
+		assert := assert.New(t)
+		w, _ := NewWord("abcd")
+		w.index = 2
+		assert.NoError(w.Change(&Change{replacement: "x"}, 2))
+		assert.Equal(w.String(), "axd")
+	})
+	t.Run("movement right", func(t *testing.T) {
+		assert := assert.New(t)
+		w, _ := NewWord("abcd")
+		w.index = 1
+		assert.NoError(w.Change(&Change{movement: 2}, 1))
+		assert.Equal(w.String(), "bcad")
+	})
+	t.Run("movement left", func(t *testing.T) {
+		assert := assert.New(t)
+		w, _ := NewWord("abcd")
+		w.index = 4
+		assert.NoError(w.Change(&Change{movement: -2}, 1))
+		assert.Equal(w.String(), "adbc")
+	})
+	t.Run("deletion", func(t *testing.T) {
+		assert := assert.New(t)
+		w, _ := NewWord("abcd")
+		w.index = 2
+		assert.NoError(w.Change(&Change{deletion: true}, 2))
+		assert.Equal(w.String(), "ad")
+	})
+}